@@ -8,6 +8,8 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	// The container image has no zoneinfo; digest timezones need it.
+	_ "time/tzdata"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/spf13/cobra"
@@ -56,12 +58,14 @@ func init() {
 	rootCmd.PersistentFlags().String("transmission-url", "", "Transmission RPC URL")
 	rootCmd.PersistentFlags().String("transmission-username", "", "Transmission username")
 	rootCmd.PersistentFlags().String("transmission-password", "", "Transmission password")
+	rootCmd.PersistentFlags().String("storage-path", "", "Path to the persistent state file")
 
 	_ = viper.BindPFlag("telegram.token", rootCmd.PersistentFlags().Lookup("telegram-token"))
 	_ = viper.BindPFlag("telegram.allowed_users", rootCmd.PersistentFlags().Lookup("telegram-allowed-users"))
 	_ = viper.BindPFlag("transmission.url", rootCmd.PersistentFlags().Lookup("transmission-url"))
 	_ = viper.BindPFlag("transmission.username", rootCmd.PersistentFlags().Lookup("transmission-username"))
 	_ = viper.BindPFlag("transmission.password", rootCmd.PersistentFlags().Lookup("transmission-password"))
+	_ = viper.BindPFlag("storage.path", rootCmd.PersistentFlags().Lookup("storage-path"))
 	_ = viper.BindPFlag("log.level", rootCmd.PersistentFlags().Lookup("log-level"))
 }
 
@@ -77,7 +81,9 @@ func run(_ *cobra.Command, _ []string) error {
 
 	logger.Info("configuration loaded",
 		"transmission_url", cfg.Transmission.URL,
+		"transmission_instances", len(cfg.Transmission.InstanceList()),
 		"allowed_users", cfg.Telegram.AllowedUsers,
+		"storage_path", cfg.Storage.Path,
 	)
 
 	telegramBot, err := bot.New(cfg, logger)
@@ -0,0 +1,206 @@
+// Package bencode checks that data is a well-formed bencoded .torrent file.
+package bencode
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Errors returned by ValidateTorrent.
+var (
+	// ErrMalformed means the data is not valid bencode.
+	ErrMalformed = errors.New("malformed bencode")
+	// ErrMissingInfo means the data is bencode but has no info dictionary.
+	ErrMissingInfo = errors.New("no info dictionary")
+)
+
+// maxDepth bounds the nesting of lists and dictionaries, so that hostile
+// input cannot exhaust the stack.
+const maxDepth = 64
+
+// ValidateTorrent checks that data is a single bencoded dictionary with an
+// info dictionary, as every .torrent file is. It does not check the
+// contents of info; Transmission does that.
+func ValidateTorrent(data []byte) error {
+	dec := &decoder{data: data}
+
+	if !dec.consume('d') {
+		return dec.malformed("expected a dictionary")
+	}
+
+	hasInfo := false
+
+	for !dec.consume('e') {
+		key, err := dec.str()
+		if err != nil {
+			return err
+		}
+
+		start := dec.pos
+
+		err = dec.value(1)
+		if err != nil {
+			return err
+		}
+
+		if string(key) == "info" {
+			if data[start] != 'd' {
+				return fmt.Errorf("%w: info is not a dictionary", ErrMissingInfo)
+			}
+
+			hasInfo = true
+		}
+	}
+
+	if dec.pos != len(data) {
+		return dec.malformed("trailing data")
+	}
+
+	if !hasInfo {
+		return ErrMissingInfo
+	}
+
+	return nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) malformed(reason string) error {
+	return fmt.Errorf("%w: %s at byte %d", ErrMalformed, reason, d.pos)
+}
+
+// peek returns the next byte, or 0 at the end of the data.
+func (d *decoder) peek() byte {
+	if d.pos >= len(d.data) {
+		return 0
+	}
+
+	return d.data[d.pos]
+}
+
+// consume skips the next byte if it is c.
+func (d *decoder) consume(c byte) bool {
+	if d.peek() != c {
+		return false
+	}
+
+	d.pos++
+
+	return true
+}
+
+// value skips one value of any type.
+func (d *decoder) value(depth int) error {
+	if depth > maxDepth {
+		return d.malformed("nested too deeply")
+	}
+
+	switch next := d.peek(); {
+	case next == 'i':
+		return d.integer()
+	case next == 'l':
+		d.pos++
+
+		for !d.consume('e') {
+			err := d.value(depth + 1)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case next == 'd':
+		d.pos++
+
+		for !d.consume('e') {
+			_, err := d.str()
+			if err != nil {
+				return err
+			}
+
+			err = d.value(depth + 1)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case next >= '0' && next <= '9':
+		_, err := d.str()
+
+		return err
+	default:
+		return d.malformed("unexpected value")
+	}
+}
+
+// integer skips an integer such as i42e, rejecting leading zeros and -0.
+func (d *decoder) integer() error {
+	d.pos++
+
+	digits, ok := d.until('e')
+	if !ok {
+		return d.malformed("unterminated integer")
+	}
+
+	unsigned := digits
+	if len(unsigned) > 0 && unsigned[0] == '-' {
+		unsigned = unsigned[1:]
+	}
+
+	if !isDigits(unsigned) || (len(unsigned) > 1 && unsigned[0] == '0') || digits == "-0" {
+		return d.malformed("invalid integer")
+	}
+
+	return nil
+}
+
+// str reads a byte string such as 4:spam, rejecting lengths with leading zeros.
+func (d *decoder) str() ([]byte, error) {
+	digits, ok := d.until(':')
+	if !ok || !isDigits(digits) || (len(digits) > 1 && digits[0] == '0') {
+		return nil, d.malformed("invalid string length")
+	}
+
+	length, err := strconv.Atoi(digits)
+	if err != nil || length > len(d.data)-d.pos {
+		return nil, d.malformed("string runs past the end")
+	}
+
+	value := d.data[d.pos : d.pos+length]
+	d.pos += length
+
+	return value, nil
+}
+
+// until returns the bytes up to the next delim and skips past it.
+func (d *decoder) until(delim byte) (string, bool) {
+	for end := d.pos; end < len(d.data); end++ {
+		if d.data[end] == delim {
+			text := string(d.data[d.pos:end])
+			d.pos = end + 1
+
+			return text, true
+		}
+	}
+
+	return "", false
+}
+
+func isDigits(text string) bool {
+	if text == "" {
+		return false
+	}
+
+	for _, c := range []byte(text) {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,61 @@
+package bencode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateTorrent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr error
+	}{
+		{name: "minimal torrent", data: "d4:infod4:name1:aee"},
+		{name: "all value types", data: "d8:announce3:url4:infod6:lengthi42e5:filesl1:a1:bee7:privatei-1ee"},
+		{name: "empty info", data: "d4:infodee"},
+		{name: "empty input", data: "", wantErr: ErrMalformed},
+		{name: "not a dictionary", data: "l4:infoe", wantErr: ErrMalformed},
+		{name: "no info", data: "d4:name1:ae", wantErr: ErrMissingInfo},
+		{name: "info is a string", data: "d4:info4:spame", wantErr: ErrMissingInfo},
+		{name: "info is a list", data: "d4:infoli1eee", wantErr: ErrMissingInfo},
+		{name: "unterminated dictionary", data: "d4:infodee4:name", wantErr: ErrMalformed},
+		{name: "truncated string", data: "d4:infod4:name10:shorte", wantErr: ErrMalformed},
+		{name: "string length without colon", data: "d4:infod4:name10", wantErr: ErrMalformed},
+		{name: "huge string length", data: "d4:infod4:name99999999999999999999:xee", wantErr: ErrMalformed},
+		{name: "negative string length", data: "d4:infod4:name-1:xee", wantErr: ErrMalformed},
+		{name: "string length with leading zero", data: "d4:infod4:name01:xee", wantErr: ErrMalformed},
+		{name: "non-string key", data: "di1e4:infoe", wantErr: ErrMalformed},
+		{name: "integer zero", data: "d4:infod1:ii0eee"},
+		{name: "negative zero", data: "d4:infod1:ii-0eee", wantErr: ErrMalformed},
+		{name: "integer with leading zero", data: "d4:infod1:ii042eee", wantErr: ErrMalformed},
+		{name: "negative integer with leading zero", data: "d4:infod1:ii-042eee", wantErr: ErrMalformed},
+		{name: "empty integer", data: "d4:infod1:iieee", wantErr: ErrMalformed},
+		{name: "unterminated integer", data: "d4:infod1:ii42", wantErr: ErrMalformed},
+		{name: "integer with letters", data: "d4:infod1:ii4x2eee", wantErr: ErrMalformed},
+		{name: "unknown value type", data: "d4:infod1:ixeee", wantErr: ErrMalformed},
+		{name: "trailing data", data: "d4:infodeeextra", wantErr: ErrMalformed},
+		{name: "second dictionary", data: "d4:infodeed4:infodee", wantErr: ErrMalformed},
+		{name: "nested to the limit", data: "d4:info" + nested(maxDepth-1) + "e"},
+		{name: "nested too deeply", data: "d4:info" + nested(maxDepth+1) + "e", wantErr: ErrMalformed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateTorrent([]byte(test.data))
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("ValidateTorrent(%q) error = %v, want %v", test.data, err, test.wantErr)
+			}
+		})
+	}
+}
+
+// nested returns a dictionary holding depth levels of nested lists.
+func nested(depth int) string {
+	return "d1:x" + strings.Repeat("l", depth) + strings.Repeat("e", depth) + "e"
+}
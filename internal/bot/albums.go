@@ -0,0 +1,175 @@
+package bot
+
+import (
+	"context"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// albumWindow is how long the bot waits after the latest file of an album
+// for more to arrive before adding them as one batch.
+const albumWindow = 2 * time.Second
+
+const msgAlbumDropped = "I couldn't add this album right now. Please send it again in a minute."
+
+// albumKey identifies an album: Telegram delivers its files as separate
+// messages sharing a MediaGroupID.
+type albumKey struct {
+	chatID  int64
+	groupID string
+}
+
+// album is the messages of an album received so far.
+type album struct {
+	messages []*tgbotapi.Message
+	threadID int
+	timer    *time.Timer
+	// done marks the album finished for graceful shutdown.
+	done func()
+}
+
+// albumBuffer collects the files of albums until no more arrive.
+type albumBuffer struct {
+	mu     sync.Mutex
+	albums map[albumKey]*album
+}
+
+func newAlbumBuffer() *albumBuffer {
+	return &albumBuffer{albums: make(map[albumKey]*album)}
+}
+
+func albumKeyOf(msg *tgbotapi.Message) albumKey {
+	return albumKey{chatID: msg.Chat.ID, groupID: msg.MediaGroupID}
+}
+
+// buffering reports whether files of msg's album are already waiting, so
+// that the rest of an album addressed to the bot in a group is picked up too.
+func (a *albumBuffer) buffering(msg *tgbotapi.Message) bool {
+	if msg.MediaGroupID == "" {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, ok := a.albums[albumKeyOf(msg)]
+
+	return ok
+}
+
+// bufferAlbum holds a file of an album and restarts the album's timer; the
+// album is added once no file has arrived for albumWindow.
+func (b *Bot) bufferAlbum(msg *tgbotapi.Message) {
+	key := albumKeyOf(msg)
+
+	b.albums.mu.Lock()
+	defer b.albums.mu.Unlock()
+
+	pending, ok := b.albums.albums[key]
+	if !ok {
+		threadID := b.threads.lookup(msg)
+
+		pending = &album{
+			threadID: threadID,
+			done:     b.inflight.track(incomingUpdate{Update: tgbotapi.Update{Message: msg}, threadID: threadID}),
+			timer:    time.AfterFunc(albumWindow, func() { b.flushAlbum(key) }),
+		}
+		b.albums.albums[key] = pending
+	} else {
+		pending.timer.Reset(albumWindow)
+	}
+
+	pending.messages = append(pending.messages, msg)
+}
+
+// flushAlbum queues a complete album on its chat's worker, so that it is
+// added within the worker pool's limits and in order with the chat's other updates.
+func (b *Bot) flushAlbum(key albumKey) {
+	b.albums.mu.Lock()
+	pending, ok := b.albums.albums[key]
+	delete(b.albums.albums, key)
+	b.albums.mu.Unlock()
+
+	// A reset racing with the timer fires it twice; the second run finds nothing.
+	if !ok {
+		return
+	}
+
+	slices.SortFunc(pending.messages, func(a, b *tgbotapi.Message) int {
+		return a.MessageID - b.MessageID
+	})
+
+	first := pending.messages[0]
+	if pending.threadID != 0 {
+		b.threads.remember(first, pending.threadID)
+	}
+
+	finish := func() {
+		if pending.threadID != 0 {
+			b.threads.forget(first)
+		}
+
+		pending.done()
+	}
+
+	queued := b.pool.submitFunc(key.chatID, func(ctx context.Context) {
+		b.handleAlbum(ctx, pending.messages)
+	}, finish)
+	if !queued {
+		b.logger.Warn("worker queue full or stopped, dropping album", "chat_id", key.chatID, "files", len(pending.messages))
+		b.reply(first, msgAlbumDropped)
+		finish()
+	}
+}
+
+// handleAlbum adds the .torrent files of an album as one batch, with the
+// options of the album's caption, and replies once to its first message.
+func (b *Bot) handleAlbum(ctx context.Context, messages []*tgbotapi.Message) {
+	first := messages[0]
+
+	var caption string
+
+	for _, msg := range messages {
+		if msg.Caption != "" {
+			caption = msg.Caption
+
+			break
+		}
+	}
+
+	opts, ok := b.documentOptions(first, caption)
+	if !ok {
+		return
+	}
+
+	b.logger.Debug("adding album", "user_id", first.From.ID, "files", len(messages))
+
+	sources := make([]torrentSource, 0, len(messages))
+
+	for _, msg := range messages {
+		name := msg.Document.FileName
+
+		if !strings.EqualFold(path.Ext(name), ".torrent") {
+			sources = append(sources, torrentSource{failure: name + ": not a .torrent file"})
+
+			continue
+		}
+
+		data, err := b.downloadDocument(ctx, msg.Document)
+		if err != nil {
+			b.logger.Error("failed to download file", "error", err, "file", name)
+			sources = append(sources, torrentSource{failure: name + ": " + b.downloadError(err)})
+
+			continue
+		}
+
+		sources = append(sources, fileSource(name, data))
+	}
+
+	b.addSources(ctx, first, sources, opts)
+}
@@ -0,0 +1,255 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// Values of bandwidthSchedule.applied besides window indexes.
+const (
+	notApplied      = -2
+	defaultWindowID = -1
+)
+
+// allDays is the day mask with every day of the week set.
+const allDays = 1<<7 - 1
+
+// bandwidthWindow is a configured window, parsed.
+type bandwidthWindow struct {
+	name string
+	// days is a mask of the days the window starts on, 1<<time.Weekday each.
+	days   int
+	start  int
+	end    int
+	limits transmission.SpeedLimits
+}
+
+// covers reports whether the window covers minute, a time in its zone. A
+// window spanning midnight covers the early hours of the day after its days.
+func (w *bandwidthWindow) covers(minute time.Time) bool {
+	clock := minute.Hour()*config.MinutesPerHour + minute.Minute()
+	today := w.days&(1<<minute.Weekday()) != 0
+
+	if w.start < w.end {
+		return today && clock >= w.start && clock < w.end
+	}
+
+	yesterday := w.days&(1<<minute.AddDate(0, 0, -1).Weekday()) != 0
+
+	return (today && clock >= w.start) || (yesterday && clock < w.end)
+}
+
+// bandwidthSchedule applies the speed limits of the configured windows.
+type bandwidthSchedule struct {
+	location *time.Location
+	defaults transmission.SpeedLimits
+	windows  []bandwidthWindow
+
+	mu sync.Mutex
+	// applied is the index of the window whose limits are in effect,
+	// defaultWindowID for the defaults or notApplied before the first success.
+	applied  int
+	applying bool
+}
+
+// newBandwidthSchedule parses the windows of a validated configuration.
+func newBandwidthSchedule(cfg config.BandwidthConfig) *bandwidthSchedule {
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	schedule := &bandwidthSchedule{
+		location: location,
+		defaults: transmission.SpeedLimits{Down: cfg.Default.Down, Up: cfg.Default.Up},
+		applied:  notApplied,
+	}
+
+	for _, window := range cfg.Windows {
+		parsed := bandwidthWindow{
+			name:   window.Name,
+			limits: transmission.SpeedLimits{Down: window.Limits.Down, Up: window.Limits.Up},
+		}
+
+		parsed.start, _ = config.ParseClock(window.Start)
+		parsed.end, _ = config.ParseClock(window.End)
+
+		for _, day := range window.Days {
+			weekday, _ := config.ParseWeekday(day)
+			parsed.days |= 1 << weekday
+		}
+
+		if parsed.days == 0 {
+			parsed.days = allDays
+		}
+
+		schedule.windows = append(schedule.windows, parsed)
+	}
+
+	return schedule
+}
+
+// active returns the index of the first window covering minute, or defaultWindowID.
+func (s *bandwidthSchedule) active(minute time.Time) int {
+	local := minute.In(s.location)
+
+	for i := range s.windows {
+		if s.windows[i].covers(local) {
+			return i
+		}
+	}
+
+	return defaultWindowID
+}
+
+func (s *bandwidthSchedule) limits(window int) transmission.SpeedLimits {
+	if window == defaultWindowID {
+		return s.defaults
+	}
+
+	return s.windows[window].limits
+}
+
+// begin reports whether window should be applied now: it isn't in effect yet
+// and no other change is running.
+func (s *bandwidthSchedule) begin(window int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.applying || s.applied == window {
+		return false
+	}
+
+	s.applying = true
+
+	return true
+}
+
+// finish records the outcome of applying window; a failure is retried at the next minute.
+func (s *bandwidthSchedule) finish(window int, applied bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.applying = false
+
+	if applied {
+		s.applied = window
+	}
+}
+
+// applyBandwidth is the scheduler job that sets the speed limits of every
+// instance when a window starts or ends. The current window is applied at the
+// first minute after startup too, so a restart catches up.
+func (b *Bot) applyBandwidth(ctx context.Context, minute time.Time) {
+	schedule := b.bandwidth
+	if len(schedule.windows) == 0 {
+		return
+	}
+
+	window := schedule.active(minute)
+	if !schedule.begin(window) {
+		return
+	}
+
+	b.background.start(ctx, func(ctx context.Context) {
+		limits := schedule.limits(window)
+		applied := true
+
+		for _, client := range b.instances {
+			err := client.SetSpeedLimits(ctx, limits)
+			if err != nil {
+				b.logger.Warn("failed to apply bandwidth window", "error", err, "instance", client.Name())
+
+				applied = false
+			}
+		}
+
+		schedule.finish(window, applied)
+
+		if applied {
+			b.logger.Info("applied bandwidth window", "window", schedule.windowName(window),
+				"down", limits.Down, "up", limits.Up)
+		}
+	})
+}
+
+func (s *bandwidthSchedule) windowName(window int) string {
+	if window == defaultWindowID {
+		return "default"
+	}
+
+	return s.windows[window].name
+}
+
+// describe lists the windows for /schedule, marking the one in effect at now.
+func (s *bandwidthSchedule) describe(now time.Time) string {
+	var text strings.Builder
+
+	fmt.Fprintf(&text, "Bot schedule (%s):\n", s.location)
+
+	active := s.active(now)
+
+	for i := range s.windows {
+		window := &s.windows[i]
+
+		fmt.Fprintf(&text, "%s: %s %s-%s, %s", window.name, formatDays(window.days),
+			formatClock(window.start), formatClock(window.end), formatLimits(window.limits))
+
+		if i == active {
+			text.WriteString(" (now)")
+		}
+
+		text.WriteString("\n")
+	}
+
+	fmt.Fprintf(&text, "Otherwise: %s", formatLimits(s.defaults))
+
+	if active == defaultWindowID {
+		text.WriteString(" (now)")
+	}
+
+	return text.String()
+}
+
+func formatLimits(limits transmission.SpeedLimits) string {
+	return fmt.Sprintf("↓ %s ↑ %s", formatLimit(limits.Down), formatLimit(limits.Up))
+}
+
+func formatLimit(limit int64) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+
+	return fmt.Sprintf("%d KB/s", limit)
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/config.MinutesPerHour, minutes%config.MinutesPerHour)
+}
+
+// formatDays lists the days of a day mask, as in "Mon Tue", or "every day".
+func formatDays(days int) string {
+	if days&allDays == allDays {
+		return "every day"
+	}
+
+	var names []string
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if days&(1<<day) != 0 {
+			names = append(names, day.String()[:len("Mon")])
+		}
+	}
+
+	if len(names) == 0 {
+		return "no days"
+	}
+
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBandwidthWindowCovers(t *testing.T) {
+	t.Parallel()
+
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	weekdays := allDays &^ (1<<time.Saturday | 1<<time.Sunday)
+	fridays := 1 << time.Friday
+
+	office := &bandwidthWindow{name: "office", days: weekdays, start: 9 * 60, end: 18 * 60}
+	night := &bandwidthWindow{name: "night", days: fridays, start: 23 * 60, end: 6 * 60}
+	evening := &bandwidthWindow{name: "evening", days: allDays, start: 20 * 60, end: 0}
+
+	tests := []struct {
+		name   string
+		window *bandwidthWindow
+		minute time.Time
+		want   bool
+	}{
+		{name: "inside", window: office, minute: monday.Add(12 * time.Hour), want: true},
+		{name: "start is inside", window: office, minute: monday.Add(9 * time.Hour), want: true},
+		{name: "end is outside", window: office, minute: monday.Add(18 * time.Hour)},
+		{name: "before start", window: office, minute: monday.Add(8*time.Hour + 59*time.Minute)},
+		{name: "wrong day", window: office, minute: monday.AddDate(0, 0, -1).Add(12 * time.Hour)},
+		{name: "night on its day", window: night, minute: monday.AddDate(0, 0, 4).Add(23*time.Hour + 30*time.Minute), want: true},
+		{name: "night after midnight", window: night, minute: monday.AddDate(0, 0, 5).Add(5 * time.Hour), want: true},
+		{name: "night ends", window: night, minute: monday.AddDate(0, 0, 5).Add(6 * time.Hour)},
+		{name: "night on another day", window: night, minute: monday.Add(23*time.Hour + 30*time.Minute)},
+		{name: "night after another day", window: night, minute: monday.Add(time.Hour)},
+		{name: "ends at midnight", window: evening, minute: monday.Add(23*time.Hour + 59*time.Minute), want: true},
+		{name: "midnight is outside", window: evening, minute: monday},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := test.window.covers(test.minute); got != test.want {
+				t.Errorf("%s.covers(%s) = %v, want %v", test.window.name, test.minute, got, test.want)
+			}
+		})
+	}
+}
@@ -4,55 +4,174 @@ package bot
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
-	"regexp"
+	"path"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
+	"github.com/lexfrei/transmission-bot/internal/bencode"
 	"github.com/lexfrei/transmission-bot/internal/config"
+	"github.com/lexfrei/transmission-bot/internal/metrics"
+	"github.com/lexfrei/transmission-bot/internal/store"
 	"github.com/lexfrei/transmission-bot/internal/transmission"
 )
 
-var magnetRegex = regexp.MustCompile(`magnet:\?xt=urn:[a-zA-Z0-9]+:[a-zA-Z0-9]+[^\s]*`)
+// receiverStopTimeout bounds the wait for the update receiver at shutdown; it
+// leaves the webhook server time to shut down and the webhook to be deleted.
+const receiverStopTimeout = webhookShutdownTimeout + 5*time.Second
+
+var (
+	errFileTooLarge   = errors.New("file too large")
+	errDownloadStatus = errors.New("unexpected HTTP status")
+)
 
 // Bot represents the Telegram bot instance.
 type Bot struct {
-	api          *tgbotapi.BotAPI
-	trClient     *transmission.Client
-	allowedUsers map[int64]struct{}
-	logger       *slog.Logger
+	api            *tgbotapi.BotAPI
+	instances      []*transmission.Client
+	store          *store.Store
+	roles          map[int64]config.Role
+	allowedChats   map[int64]struct{}
+	threads        *threadIndex
+	inviteTTL      time.Duration
+	webhook        config.WebhookConfig
+	healthListen   string
+	metrics        *metrics.Metrics
+	inflight       *inflightTracker
+	gracePeriod    time.Duration
+	workers        int
+	pool           *workerPool
+	limiter        *userLimiter
+	sender         *sender
+	uploadClient   *http.Client
+	maxUploadSize  int64
+	downloadDirs   []string
+	fetcher        *torrentFetcher
+	importer       *importer
+	albums         *albumBuffer
+	cards          *progressCards
+	dashboards     *dashboards
+	scheduler      *scheduler
+	notifier       *notifier
+	bandwidth      *bandwidthSchedule
+	stateAlerts    *stateAlerts
+	prompts        *prompts
+	editable       map[string]struct{}
+	background     *backgroundTasks
+	trackers       []string
+	healthInterval time.Duration
+	logger         *slog.Logger
 }
 
-// New creates a new Bot instance with the given configuration.
+// New creates a new Bot instance with the given configuration. The editable
+// settings are checked first, so that a typo fails before contacting Telegram.
 func New(cfg *config.Config, logger *slog.Logger) (*Bot, error) {
+	editable, err := editableSettings(cfg.Settings)
+	if err != nil {
+		return nil, err
+	}
+
 	api, err := tgbotapi.NewBotAPI(cfg.Telegram.Token)
 	if err != nil {
 		return nil, fmt.Errorf("creating telegram bot: %w", err)
 	}
 
-	trClient, err := transmission.NewClient(cfg.Transmission)
+	stateStore, err := store.Open(cfg.Storage.Path)
 	if err != nil {
-		return nil, fmt.Errorf("creating transmission client: %w", err)
+		return nil, fmt.Errorf("opening state store: %w", err)
+	}
+
+	bot := &Bot{
+		api:            api,
+		store:          stateStore,
+		roles:          cfg.Telegram.Roles(),
+		allowedChats:   chatSet(cfg.Telegram.AllowedChats),
+		threads:        newThreadIndex(),
+		inviteTTL:      cfg.Telegram.InviteTTL,
+		webhook:        cfg.Telegram.Webhook,
+		healthListen:   cfg.Health.Listen,
+		metrics:        metrics.New(),
+		inflight:       newInflightTracker(),
+		gracePeriod:    cfg.Shutdown.GracePeriod,
+		workers:        cfg.Limits.Workers,
+		limiter:        newUserLimiter(cfg.Limits.UserRatePerMinute, cfg.Limits.UserBurst),
+		sender:         newSender(cfg.Limits.SendRatePerSecond, cfg.Limits.ChatSendsPerMinute, logger),
+		uploadClient:   &http.Client{Timeout: cfg.Upload.Timeout},
+		maxUploadSize:  cfg.Upload.MaxSize,
+		downloadDirs:   cfg.Upload.DownloadDirs,
+		fetcher:        newTorrentFetcher(cfg.Fetch),
+		importer:       newImporter(cfg.Import),
+		albums:         newAlbumBuffer(),
+		cards:          newProgressCards(cfg.Progress),
+		dashboards:     newDashboards(cfg.Dashboard.Interval),
+		scheduler:      newScheduler(),
+		notifier:       newNotifier(cfg.Notify),
+		bandwidth:      newBandwidthSchedule(cfg.Bandwidth),
+		stateAlerts:    newStateAlerts(),
+		prompts:        newPrompts(),
+		editable:       editable,
+		background:     newBackgroundTasks(),
+		trackers:       cfg.Magnet.Trackers,
+		healthInterval: cfg.Transmission.HealthInterval,
+		logger:         logger,
+	}
+
+	clientsErr := bot.connectInstances(cfg.Transmission)
+	if clientsErr != nil {
+		return nil, clientsErr
+	}
+
+	bot.registerJobs()
+
+	return bot, nil
+}
+
+// chatSet turns a list of chat IDs into a set.
+func chatSet(chatIDs []int64) map[int64]struct{} {
+	chats := make(map[int64]struct{}, len(chatIDs))
+	for _, chatID := range chatIDs {
+		chats[chatID] = struct{}{}
 	}
 
-	allowedUsers := make(map[int64]struct{}, len(cfg.Telegram.AllowedUsers))
-	for _, userID := range cfg.Telegram.AllowedUsers {
-		allowedUsers[userID] = struct{}{}
+	return chats
+}
+
+// connectInstances creates a client for every configured Transmission instance.
+func (b *Bot) connectInstances(cfg config.TransmissionConfig) error {
+	for _, instance := range cfg.InstanceList() {
+		client, err := transmission.NewClient(cfg, instance,
+			transmission.WithErrorHook(b.metrics.ObserveRPCError),
+			transmission.WithStateHook(func(from, to transmission.State) {
+				b.onTransmissionState(instance.Name, from, to)
+			}),
+		)
+		if err != nil {
+			return fmt.Errorf("creating transmission client for %s: %w", instance.Name, err)
+		}
+
+		b.instances = append(b.instances, client)
 	}
 
-	return &Bot{
-		api:          api,
-		trClient:     trClient,
-		allowedUsers: allowedUsers,
-		logger:       logger,
-	}, nil
+	return nil
+}
+
+// registerJobs adds the bot's periodic jobs to the scheduler.
+func (b *Bot) registerJobs() {
+	b.scheduler.register(b.sendDueDigests)
+	b.scheduler.register(b.releaseHeld)
+	b.scheduler.register(b.applyBandwidth)
 }
 
-// Run starts the bot and blocks until the context is cancelled.
+// Run starts the bot and blocks until the context is cancelled. On
+// cancellation it stops receiving updates and lets running handlers and then
+// background tasks finish, each within the configured grace period, before
+// closing the Transmission clients.
 func (b *Bot) Run(ctx context.Context) error {
 	registerErr := b.registerCommands()
 	if registerErr != nil {
@@ -61,188 +180,520 @@ func (b *Bot) Run(ctx context.Context) error {
 
 	b.logger.Info("bot started", "username", b.api.Self.UserName)
 
-	updateConfig := tgbotapi.NewUpdate(0)
-	updateConfig.Timeout = 60
+	b.startMonitoring(ctx)
+
+	// Handlers outlive ctx so that a shutdown signal does not abort them mid-RPC.
+	handlerCtx, cancelHandlers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHandlers()
+
+	// The sender stays up until handlers are drained so their last replies go out.
+	senderCtx, stopSender := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopSender()
+
+	go b.sender.run(senderCtx)
+
+	b.pool = newWorkerPool(handlerCtx, b.workers, b.handleUpdate)
+
+	updates := make(chan incomingUpdate)
+	receiveErr := make(chan error, 1)
 
-	updates := b.api.GetUpdatesChan(updateConfig)
+	go func() {
+		receiveErr <- b.receiveUpdates(ctx, updates)
+	}()
+
+	shutdown := func() {
+		b.pool.stop()
+		b.drain(cancelHandlers)
+		b.stopBackground()
+		stopSender()
+		b.closeTransmission()
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			b.logger.Info("shutting down bot")
-			b.api.StopReceivingUpdates()
-
-			closeErr := b.trClient.Close()
-			if closeErr != nil {
-				b.logger.Error("failed to close transmission client", "error", closeErr)
-			}
+			shutdown()
+			b.awaitReceiver(receiveErr)
 
 			return nil
-		case update := <-updates:
-			if update.Message == nil {
-				continue
+		case err := <-receiveErr:
+			if err != nil {
+				shutdown()
+
+				return fmt.Errorf("receiving updates: %w", err)
 			}
+		case update := <-updates:
+			b.dispatch(update)
+		}
+	}
+}
+
+// dispatch hands an update to its chat's worker, or tells the user the bot is
+// busy when that worker's queue is full.
+func (b *Bot) dispatch(update incomingUpdate) {
+	if update.Message == nil && update.CallbackQuery == nil {
+		return
+	}
+
+	done := b.inflight.track(update)
+	if !b.pool.submit(update, done) {
+		done()
 
-			go b.handleUpdate(ctx, update)
+		// Replying waits for the sender; keep receiving meanwhile.
+		go b.rejectBusy(update)
+	}
+}
+
+// awaitReceiver waits for receiveUpdates to return after cancellation, so
+// that the webhook server is shut down and the webhook removed before exit. A
+// long poll can't be interrupted, so the wait is bounded.
+func (b *Bot) awaitReceiver(receiveErr <-chan error) {
+	timer := time.NewTimer(receiverStopTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-receiveErr:
+		if err != nil {
+			b.logger.Error("update receiver stopped with an error", "error", err)
 		}
+	case <-timer.C:
+		b.logger.Warn("update receiver did not stop in time", "timeout", receiverStopTimeout)
 	}
 }
 
-func (b *Bot) registerCommands() error {
-	commands := []tgbotapi.BotCommand{
-		{Command: "start", Description: "Start the bot"},
-		{Command: "help", Description: "Show help message"},
-		{Command: "list", Description: "List all torrents"},
-		{Command: "remove", Description: "Remove torrent by ID"},
+// startMonitoring starts the optional health server and Transmission watcher,
+// the dashboard refresher, the notification watcher and the scheduler.
+func (b *Bot) startMonitoring(ctx context.Context) {
+	if b.healthListen != "" {
+		b.metrics.RegisterTorrentCounter(b.countTorrents)
+
+		b.background.start(ctx, b.serveHealth)
 	}
 
-	cfg := tgbotapi.NewSetMyCommands(commands...)
+	if b.healthInterval > 0 {
+		b.background.start(ctx, b.watchTransmission)
+	}
+
+	b.background.start(ctx, b.watchDashboards)
+	b.background.start(ctx, b.watchNotifications)
+	b.background.start(ctx, b.scheduler.run)
+}
 
-	_, err := b.api.Request(cfg)
+func (b *Bot) closeTransmission() {
+	for _, client := range b.instances {
+		closeErr := client.Close()
+		if closeErr != nil {
+			b.logger.Error("failed to close transmission client", "error", closeErr, "instance", client.Name())
+		}
+	}
+}
+
+// registerCommands sets a minimal default menu and a per-user menu that
+// lists only the commands allowed for that user's role.
+func (b *Bot) registerCommands() error {
+	defaultMenu := tgbotapi.NewSetMyCommands(
+		tgbotapi.BotCommand{Command: "start", Description: "Start the bot"},
+		tgbotapi.BotCommand{Command: "help", Description: "Show help message"},
+	)
+
+	_, err := b.api.Request(defaultMenu)
 	if err != nil {
 		return fmt.Errorf("setting commands: %w", err)
 	}
 
+	for userID, role := range b.roles {
+		b.registerUserCommands(userID, role)
+	}
+
+	for _, grant := range b.store.Grants() {
+		if _, static := b.roles[grant.UserID]; !static {
+			b.registerUserCommands(grant.UserID, grant.Role)
+		}
+	}
+
+	b.registerChatCommands()
+
 	b.logger.Debug("commands registered")
 
 	return nil
 }
 
-func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) {
+// registerUserCommands scopes the command menu to the user's private chat.
+// Telegram rejects scopes for users who never started the bot, so failures are only logged.
+func (b *Bot) registerUserCommands(userID int64, role config.Role) {
+	userMenu := tgbotapi.NewSetMyCommandsWithScope(tgbotapi.NewBotCommandScopeChat(userID), menuFor(role)...)
+
+	_, err := b.api.Request(userMenu)
+	if err != nil {
+		b.logger.Warn("failed to set user commands", "error", err, "user_id", userID, "role", role)
+	}
+}
+
+// unregisterUserCommands drops the user's scoped menu so they fall back to the default one.
+func (b *Bot) unregisterUserCommands(userID int64) {
+	_, err := b.api.Request(tgbotapi.NewDeleteMyCommandsWithScope(tgbotapi.NewBotCommandScopeChat(userID)))
+	if err != nil {
+		b.logger.Warn("failed to delete user commands", "error", err, "user_id", userID)
+	}
+}
+
+func (b *Bot) handleUpdate(ctx context.Context, update incomingUpdate) {
+	b.metrics.ObserveUpdate(updateType(update))
+
+	if update.CallbackQuery != nil {
+		if update.threadID != 0 && update.CallbackQuery.Message != nil {
+			b.threads.remember(update.CallbackQuery.Message, update.threadID)
+			defer b.threads.forget(update.CallbackQuery.Message)
+		}
+
+		b.handleCallback(ctx, update.CallbackQuery)
+
+		return
+	}
+
 	msg := update.Message
-	userID := msg.From.ID
 
-	if !b.isAllowed(userID) {
-		b.logger.Warn("unauthorized access attempt",
-			"user_id", userID,
-			"username", msg.From.UserName,
-		)
+	if msg.IsCommand() && !b.commandForMe(msg) {
+		return
+	}
+
+	if !msg.Chat.IsPrivate() && !b.acceptGroupMessage(msg) {
+		return
+	}
+
+	if update.threadID != 0 {
+		b.threads.remember(msg, update.threadID)
+		defer b.threads.forget(msg)
+	}
 
+	if !b.authorize(msg) {
 		return
 	}
 
 	b.logger.Debug("received message",
-		"user_id", userID,
+		"user_id", msg.From.ID,
 		"text", msg.Text,
 		"has_document", msg.Document != nil,
 	)
 
+	b.handleMessage(ctx, msg)
+}
+
+// authorize checks that the sender has a role and is within their rate limit.
+// An album counts as one request: only its first file takes a token.
+// Unknown users may still redeem an invite via /start <code>.
+func (b *Bot) authorize(msg *tgbotapi.Message) bool {
+	userID := msg.From.ID
+
+	if _, ok := b.roleOf(userID); !ok {
+		if code := startPayload(msg); code != "" {
+			b.redeemInvite(msg, code)
+
+			return false
+		}
+
+		b.logger.Warn("unauthorized access attempt",
+			"user_id", userID,
+			"username", msg.From.UserName,
+		)
+
+		return false
+	}
+
+	b.rememberUser(msg)
+
+	if b.albums.buffering(msg) {
+		return true
+	}
+
+	allowed, warn := b.limiter.allow(userID)
+	if !allowed {
+		b.logger.Debug("rate limited", "user_id", userID)
+
+		if warn {
+			b.reply(msg, msgSlowDown)
+		}
+
+		return false
+	}
+
+	return true
+}
+
+func (b *Bot) handleMessage(ctx context.Context, msg *tgbotapi.Message) {
 	if msg.IsCommand() {
 		b.handleCommand(ctx, msg)
 
 		return
 	}
 
-	if msg.Document != nil {
-		b.handleDocument(ctx, msg)
+	if b.answerPrompt(ctx, msg) {
+		return
+	}
 
+	links := b.findLinks(b.withoutMention(msg.Text), false)
+	if msg.Document == nil && len(links) == 0 {
 		return
 	}
 
-	if magnets := magnetRegex.FindAllString(msg.Text, -1); len(magnets) > 0 {
-		b.handleMagnets(ctx, msg, magnets)
+	if !b.can(msg.From.ID, permAdd) {
+		b.reply(msg, msgNotPermitted)
 
 		return
 	}
+
+	if msg.Document != nil && msg.MediaGroupID != "" {
+		b.bufferAlbum(msg)
+
+		return
+	}
+
+	if msg.Document != nil {
+		b.handleDocument(ctx, msg)
+
+		return
+	}
+
+	b.addSources(ctx, msg, parseLinks(links), addOptionsFor(msg.From))
 }
 
-func (b *Bot) isAllowed(userID int64) bool {
-	_, ok := b.allowedUsers[userID]
+// startPayload returns the deep-link payload of a /start command, if any.
+func startPayload(msg *tgbotapi.Message) string {
+	if !msg.IsCommand() || msg.Command() != "start" {
+		return ""
+	}
 
-	return ok
+	return strings.TrimSpace(msg.CommandArguments())
 }
 
 func (b *Bot) handleDocument(ctx context.Context, msg *tgbotapi.Message) {
 	doc := msg.Document
-	if !strings.HasSuffix(strings.ToLower(doc.FileName), ".torrent") {
-		b.reply(msg, "Please send a .torrent file")
+
+	extension := strings.ToLower(path.Ext(doc.FileName))
+	if extension == ".txt" || extension == ".zip" {
+		b.handleImport(ctx, msg, extension)
 
 		return
 	}
 
-	fileURL, err := b.api.GetFileDirectURL(doc.FileID)
-	if err != nil {
-		b.logger.Error("failed to get file URL", "error", err)
-		b.reply(msg, "Failed to download file")
+	if extension != ".torrent" {
+		b.reply(msg, "Please send a .torrent file, or a .txt or .zip file with several torrents")
 
 		return
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	opts, ok := b.documentOptions(msg, msg.Caption)
+	if !ok {
+		return
+	}
+
+	data, ok := b.readTorrentDocument(ctx, msg)
+	if !ok {
+		return
+	}
+
+	base64Data := base64.StdEncoding.EncodeToString(data)
+
+	client := b.currentInstance(msg.From.ID)
+
+	result, err := client.AddTorrentByFile(ctx, base64Data, opts)
 	if err != nil {
-		b.logger.Error("failed to create request", "error", err)
-		b.reply(msg, "Failed to download file")
+		b.logger.Error("failed to add torrent", "error", err, "instance", client.Name())
+		b.reply(msg, b.instanceFailure(client, "Failed to add torrent", err))
 
 		return
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	if result.Duplicate {
+		existing := b.existingTorrent(ctx, client, &result.Torrent)
+
+		b.replyWithMarkup(msg,
+			fmt.Sprintf("Already exists: ID %s, %s\nName: %s", b.torrentRef(existing), torrentStatus(existing), existing.Name),
+			tgbotapi.NewInlineKeyboardMarkup(torrentButtons(existing, "")),
+		)
+
+		return
+	}
+
+	b.torrentAdded(&result.Torrent, msg.From)
+	b.replyAdded(ctx, msg, client, &result.Torrent)
+}
+
+// readTorrentDocument downloads a sent .torrent file and checks that it is
+// well-formed, replying with the reason when it isn't.
+func (b *Bot) readTorrentDocument(ctx context.Context, msg *tgbotapi.Message) ([]byte, bool) {
+	data, err := b.downloadDocument(ctx, msg.Document)
 	if err != nil {
 		b.logger.Error("failed to download file", "error", err)
-		b.reply(msg, "Failed to download file")
+		b.reply(msg, b.downloadError(err))
 
-		return
+		return nil, false
 	}
 
-	defer func() { _ = resp.Body.Close() }()
+	err = bencode.ValidateTorrent(data)
+	if err != nil {
+		b.logger.Info("rejected invalid torrent file", "error", err, "file", msg.Document.FileName)
+		b.reply(msg, userError("Failed to read torrent file", err))
+
+		return nil, false
+	}
+
+	return data, true
+}
+
+// downloadDocument fetches the content of a file sent to the bot, refusing
+// files over the upload limit by their announced size and by what is read.
+func (b *Bot) downloadDocument(ctx context.Context, doc *tgbotapi.Document) ([]byte, error) {
+	if int64(doc.FileSize) > b.maxUploadSize {
+		return nil, fmt.Errorf("%w: %d bytes", errFileTooLarge, doc.FileSize)
+	}
 
-	data, err := io.ReadAll(resp.Body)
+	fileURL, err := b.api.GetFileDirectURL(doc.FileID)
 	if err != nil {
-		b.logger.Error("failed to read file", "error", err)
-		b.reply(msg, "Failed to read file")
+		return nil, fmt.Errorf("getting file URL: %w", err)
+	}
 
-		return
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
-	base64Data := base64.StdEncoding.EncodeToString(data)
+	resp, err := b.uploadClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading file: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
 
-	torrent, err := b.trClient.AddTorrentByFile(ctx, base64Data)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", errDownloadStatus, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, b.maxUploadSize+1))
 	if err != nil {
-		b.logger.Error("failed to add torrent", "error", err)
-		b.reply(msg, fmt.Sprintf("Failed to add torrent: %v", err))
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
 
-		return
+	if int64(len(data)) > b.maxUploadSize {
+		return nil, fmt.Errorf("%w: over %d bytes", errFileTooLarge, b.maxUploadSize)
 	}
 
+	return data, nil
+}
+
+// downloadError explains to the user why a sent file could not be downloaded.
+func (b *Bot) downloadError(err error) string {
+	if errors.Is(err, errFileTooLarge) {
+		return fmt.Sprintf("The file is too large: at most %s is accepted.", formatBytes(b.maxUploadSize))
+	}
+
+	return "Failed to download file"
+}
+
+// torrentAdded logs, counts and records the owner of a newly added torrent.
+func (b *Bot) torrentAdded(torrent *transmission.Torrent, user *tgbotapi.User) {
 	b.logger.Info("torrent added",
 		"id", torrent.ID,
+		"instance", torrent.Instance,
 		"name", torrent.Name,
-		"user_id", msg.From.ID,
+		"user_id", user.ID,
 	)
 
-	b.reply(msg, fmt.Sprintf("Torrent added:\nID: %d\nName: %s", torrent.ID, torrent.Name))
+	b.metrics.TorrentAdded()
+	b.recordOwnership(torrent, user)
 }
 
-func (b *Bot) handleMagnets(ctx context.Context, msg *tgbotapi.Message, magnets []string) {
-	results := make([]string, 0, len(magnets))
+// duplicateKeyboard returns one row of buttons per already existing torrent, or nil if there are none.
+func (b *Bot) duplicateKeyboard(duplicates []*transmission.Torrent) any {
+	if len(duplicates) == 0 {
+		return nil
+	}
 
-	for _, magnet := range magnets {
-		torrent, err := b.trClient.AddTorrentByMagnet(ctx, magnet)
-		if err != nil {
-			b.logger.Error("failed to add magnet", "error", err)
-			results = append(results, fmt.Sprintf("Failed: %v", err))
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(duplicates))
 
-			continue
+	for _, torrent := range duplicates {
+		suffix := ""
+		if len(duplicates) > 1 {
+			suffix = " " + b.torrentRef(torrent)
 		}
 
-		b.logger.Info("torrent added",
-			"id", torrent.ID,
-			"name", torrent.Name,
-			"user_id", msg.From.ID,
-		)
-
-		results = append(results, fmt.Sprintf("ID: %d - %s", torrent.ID, torrent.Name))
+		rows = append(rows, torrentButtons(torrent, suffix))
 	}
 
-	b.reply(msg, fmt.Sprintf("Added %d torrent(s):\n%s", len(magnets), strings.Join(results, "\n")))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
 func (b *Bot) reply(msg *tgbotapi.Message, text string) {
+	b.replyWithMarkup(msg, text, nil)
+}
+
+// replyWithMarkup replies with an optional keyboard; markup may be nil.
+func (b *Bot) replyWithMarkup(msg *tgbotapi.Message, text string, markup any) {
+	b.sendReply(msg, text, markup)
+}
+
+// sendReply replies to msg and returns the sent message, reporting whether sending succeeded.
+func (b *Bot) sendReply(msg *tgbotapi.Message, text string, markup any) (tgbotapi.Message, bool) {
+	if b.inflight.wasAbandoned(msg) {
+		b.logger.Debug("dropped reply to a request abandoned at shutdown", "chat_id", msg.Chat.ID)
+
+		return tgbotapi.Message{}, false
+	}
+
 	reply := tgbotapi.NewMessage(msg.Chat.ID, text)
 	reply.ReplyToMessageID = msg.MessageID
+	reply.ReplyMarkup = markup
 
-	_, sendErr := b.api.Send(reply)
+	sent, sendErr := b.send(reply, b.threads.lookup(msg))
 	if sendErr != nil {
 		b.logger.Error("failed to send reply", "error", sendErr)
+
+		return sent, false
 	}
+
+	return sent, true
+}
+
+// edit replaces the text and buttons of a message the bot sent, through the sender queue.
+func (b *Bot) edit(chatID int64, messageID int, text string, markup *tgbotapi.InlineKeyboardMarkup) error {
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ReplyMarkup = markup
+
+	return b.sender.do(chatID, func() error {
+		_, err := b.api.Request(edit)
+		if err != nil {
+			return fmt.Errorf("editing message: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// send delivers a message through the sender queue, into the given forum topic when threadID is set.
+func (b *Bot) send(cfg tgbotapi.MessageConfig, threadID int) (tgbotapi.Message, error) {
+	var sent tgbotapi.Message
+
+	err := b.sender.doOnce(cfg.ChatID, func() error {
+		var sendErr error
+
+		sent, sendErr = b.sendMessage(cfg, threadID)
+
+		return sendErr
+	})
+
+	return sent, err
+}
+
+func (b *Bot) sendMessage(cfg tgbotapi.MessageConfig, threadID int) (tgbotapi.Message, error) {
+	if threadID != 0 {
+		return b.sendToThread(cfg, threadID)
+	}
+
+	sent, err := b.api.Send(cfg)
+	if err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("sending message: %w", err)
+	}
+
+	return sent, nil
 }
@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"context"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleCallback routes inline button presses. Callback data has the form
+// "action:args"; every action must be listed in callbackPermissions, so
+// unknown actions are rejected before any handler runs.
+func (b *Bot) handleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	if query.Message != nil && !b.chatAllowed(query.Message.Chat) {
+		b.answerCallback(query, msgNotPermitted)
+
+		return
+	}
+
+	action, args, _ := strings.Cut(query.Data, ":")
+
+	perm, known := callbackPermissions[action]
+	if !known {
+		b.logger.Warn("unknown callback action", "user_id", query.From.ID, "data", query.Data)
+		b.answerCallback(query, "Unknown action")
+
+		return
+	}
+
+	if !b.can(query.From.ID, perm) {
+		b.logger.Warn("callback not permitted",
+			"user_id", query.From.ID,
+			"username", query.From.UserName,
+			"action", action,
+		)
+		b.answerCallback(query, msgNotPermitted)
+
+		return
+	}
+
+	if allowed, _ := b.limiter.allow(query.From.ID); !allowed {
+		b.answerCallback(query, msgSlowDown)
+
+		return
+	}
+
+	switch action {
+	case callbackInfo:
+		b.handleInfoCallback(ctx, query, args)
+	case callbackResume:
+		b.handleResumeCallback(ctx, query, args)
+	case callbackNotify:
+		b.handleNotifyCallback(query, args)
+	case callbackSchedule:
+		b.handleScheduleCallback(ctx, query, args)
+	case callbackSettings:
+		b.handleSettingsCallback(ctx, query, args)
+	default:
+		b.answerCallback(query, "")
+	}
+}
+
+// answerCallback shows text to the user who pressed a button. Once drain
+// asked them to retry, the button is only acknowledged.
+func (b *Bot) answerCallback(query *tgbotapi.CallbackQuery, text string) {
+	if query.Message != nil && b.inflight.wasAbandoned(query.Message) {
+		text = ""
+	}
+
+	_, err := b.api.Request(tgbotapi.NewCallback(query.ID, text))
+	if err != nil {
+		b.logger.Error("failed to answer callback", "error", err)
+	}
+}
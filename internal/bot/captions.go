@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+var (
+	errRelativeDir   = errors.New("download directory must be an absolute path")
+	errDirNotAllowed = errors.New("download directory not allowed")
+)
+
+const (
+	captionPaused = "paused"
+	captionDir    = "dir="
+)
+
+// captionOptions applies the add options written in the caption of a sent
+// file: "paused" adds the torrents without starting them and "dir=/path"
+// downloads them to another directory, which must be one of dirs or below
+// one. Other words, such as a mention of the bot, are ignored.
+func captionOptions(opts transmission.AddOptions, caption string, dirs []string) (transmission.AddOptions, error) {
+	for _, word := range strings.Fields(caption) {
+		lower := strings.ToLower(word)
+
+		switch {
+		case lower == captionPaused:
+			opts.Paused = true
+		case strings.HasPrefix(lower, captionDir):
+			dir := word[len(captionDir):]
+			if !path.IsAbs(dir) {
+				return opts, fmt.Errorf("%w: %q", errRelativeDir, dir)
+			}
+
+			dir = path.Clean(dir)
+			if !slices.ContainsFunc(dirs, func(root string) bool { return withinDir(dir, root) }) {
+				return opts, fmt.Errorf("%w: %q", errDirNotAllowed, dir)
+			}
+
+			opts.DownloadDir = dir
+		}
+	}
+
+	return opts, nil
+}
+
+// withinDir reports whether the clean absolute path dir is root or below it.
+func withinDir(dir, root string) bool {
+	root = path.Clean(root)
+
+	return dir == root || strings.HasPrefix(dir, strings.TrimSuffix(root, "/")+"/")
+}
+
+// documentOptions returns the add options for files sent by the author of msg with caption.
+func (b *Bot) documentOptions(msg *tgbotapi.Message, caption string) (transmission.AddOptions, bool) {
+	opts, err := captionOptions(addOptionsFor(msg.From), caption, b.downloadDirs)
+
+	switch {
+	case err == nil:
+		return opts, true
+	case errors.Is(err, errDirNotAllowed) && len(b.downloadDirs) == 0:
+		b.reply(msg, "dir= is turned off on this bot.")
+	case errors.Is(err, errDirNotAllowed):
+		b.reply(msg, "Invalid dir= in the caption: allowed are "+strings.Join(b.downloadDirs, ", ")+
+			" and the folders inside them.")
+	default:
+		b.reply(msg, "Invalid dir= in the caption: please give an absolute path, like dir=/downloads/movies.")
+	}
+
+	b.logger.Debug("invalid caption options", "error", err, "user_id", msg.From.ID)
+
+	return opts, false
+}
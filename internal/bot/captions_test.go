@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+func TestCaptionOptions(t *testing.T) {
+	t.Parallel()
+
+	dirs := []string{"/downloads", "/media/"}
+
+	tests := []struct {
+		name       string
+		caption    string
+		dirs       []string
+		wantDir    string
+		wantPaused bool
+		wantErr    error
+	}{
+		{name: "no options", caption: "@bot here you go", dirs: dirs},
+		{name: "paused", caption: "PAUSED", dirs: dirs, wantPaused: true},
+		{name: "allowed root", caption: "dir=/downloads", dirs: dirs, wantDir: "/downloads"},
+		{name: "below a root", caption: "paused dir=/media/movies/", dirs: dirs, wantDir: "/media/movies", wantPaused: true},
+		{name: "outside the roots", caption: "dir=/etc", dirs: dirs, wantErr: errDirNotAllowed},
+		{name: "sibling with a shared prefix", caption: "dir=/downloads-old", dirs: dirs, wantErr: errDirNotAllowed},
+		{name: "escaping with dots", caption: "dir=/downloads/../etc", dirs: dirs, wantErr: errDirNotAllowed},
+		{name: "relative", caption: "dir=movies", dirs: dirs, wantErr: errRelativeDir},
+		{name: "turned off", caption: "dir=/downloads", wantErr: errDirNotAllowed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts, err := captionOptions(transmission.AddOptions{}, test.caption, test.dirs)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("captionOptions() error = %v, want %v", err, test.wantErr)
+			}
+
+			if err == nil && (opts.DownloadDir != test.wantDir || opts.Paused != test.wantPaused) {
+				t.Errorf("captionOptions() = %+v, want dir %q and paused %v", opts, test.wantDir, test.wantPaused)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/store"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// dashboardMaxTorrents caps the downloads listed on a dashboard, keeping it
+// well within Telegram's message length.
+const dashboardMaxTorrents = 10
+
+// dashboards remembers the text last shown on each dashboard, so that only
+// changed dashboards are edited.
+type dashboards struct {
+	mu       sync.Mutex
+	interval time.Duration
+	texts    map[int64]string
+}
+
+func newDashboards(interval time.Duration) *dashboards {
+	return &dashboards{interval: interval, texts: make(map[int64]string)}
+}
+
+// changed reports whether text differs from what the chat's dashboard shows.
+// After a restart nothing is known, so the first refresh always edits.
+func (d *dashboards) changed(chatID int64, text string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	shown, ok := d.texts[chatID]
+
+	return !ok || shown != text
+}
+
+func (d *dashboards) shown(chatID int64, text string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.texts[chatID] = text
+}
+
+func (d *dashboards) forget(chatID int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.texts, chatID)
+}
+
+// handleDashboard posts and pins a dashboard that the bot keeps up to date,
+// replacing the chat's previous one. "/dashboard off" removes it.
+func (b *Bot) handleDashboard(ctx context.Context, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	previous, hadPrevious := b.store.Dashboard(chatID)
+
+	if strings.TrimSpace(msg.CommandArguments()) == "off" {
+		if !hadPrevious {
+			b.reply(msg, "There is no dashboard in this chat.")
+
+			return
+		}
+
+		b.removeDashboard(previous)
+		b.reply(msg, "Dashboard removed.")
+
+		return
+	}
+
+	text := b.renderDashboard(ctx)
+
+	sent, err := b.send(tgbotapi.NewMessage(chatID, text), b.threads.lookup(msg))
+	if err != nil {
+		b.logger.Error("failed to send dashboard", "error", err, "chat_id", chatID)
+
+		return
+	}
+
+	dashboard := store.Dashboard{ChatID: chatID, MessageID: sent.MessageID}
+
+	saveErr := b.store.SetDashboard(dashboard)
+	if saveErr != nil {
+		b.logger.Error("failed to save dashboard", "error", saveErr, "chat_id", chatID)
+		b.reply(msg, "Failed to save the dashboard: it won't be kept up to date.")
+
+		return
+	}
+
+	b.dashboards.shown(chatID, text)
+
+	if hadPrevious {
+		b.deleteMessage(chatID, previous.MessageID)
+	}
+
+	pinErr := b.pin(chatID, sent.MessageID)
+	if pinErr != nil {
+		b.logger.Warn("failed to pin dashboard", "error", pinErr, "chat_id", chatID)
+		b.reply(msg, "The dashboard will be kept up to date, but I couldn't pin it: "+
+			"please allow me to pin messages or pin it yourself.")
+	}
+}
+
+// removeDashboard forgets a dashboard and deletes its message.
+func (b *Bot) removeDashboard(dashboard store.Dashboard) {
+	err := b.store.RemoveDashboard(dashboard.ChatID, dashboard.MessageID)
+	if err != nil {
+		b.logger.Error("failed to remove dashboard", "error", err, "chat_id", dashboard.ChatID)
+	}
+
+	b.dashboards.forget(dashboard.ChatID)
+	b.deleteMessage(dashboard.ChatID, dashboard.MessageID)
+}
+
+// watchDashboards refreshes every dashboard periodically. The stored
+// dashboards are picked up again after a restart.
+func (b *Bot) watchDashboards(ctx context.Context) {
+	ticker := time.NewTicker(b.dashboards.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		saved := b.store.Dashboards()
+		if len(saved) == 0 {
+			continue
+		}
+
+		text := b.renderDashboard(ctx)
+
+		for _, dashboard := range saved {
+			b.refreshDashboard(dashboard, text)
+		}
+	}
+}
+
+// refreshDashboard edits a dashboard if its text changed. A dashboard whose
+// message or chat is gone is forgotten.
+func (b *Bot) refreshDashboard(dashboard store.Dashboard, text string) {
+	if !b.dashboards.changed(dashboard.ChatID, text) {
+		return
+	}
+
+	err := b.edit(dashboard.ChatID, dashboard.MessageID, text, nil)
+
+	switch {
+	case err == nil, isNotModified(err):
+		b.dashboards.shown(dashboard.ChatID, text)
+	case isMessageGone(err):
+		b.logger.Info("dashboard is gone, forgetting it", "error", err, "chat_id", dashboard.ChatID)
+
+		removeErr := b.store.RemoveDashboard(dashboard.ChatID, dashboard.MessageID)
+		if removeErr != nil {
+			b.logger.Error("failed to remove dashboard", "error", removeErr, "chat_id", dashboard.ChatID)
+		}
+
+		b.dashboards.forget(dashboard.ChatID)
+	default:
+		b.logger.Warn("failed to update dashboard", "error", err, "chat_id", dashboard.ChatID)
+	}
+}
+
+// renderDashboard describes every instance, followed by the active downloads.
+// It holds no timestamps so that an idle dashboard is not edited at all.
+func (b *Bot) renderDashboard(ctx context.Context) string {
+	var (
+		text        strings.Builder
+		downloading []transmission.Torrent
+	)
+
+	text.WriteString("Dashboard\n")
+
+	for _, client := range b.instances {
+		text.WriteString("\n")
+
+		downloading = append(downloading, b.writeInstanceStatus(ctx, &text, client)...)
+	}
+
+	if len(downloading) == 0 {
+		text.WriteString("\nNo active downloads.")
+
+		return strings.TrimSpace(text.String())
+	}
+
+	fmt.Fprintf(&text, "\nDownloading (%d):\n", len(downloading))
+
+	for i := range downloading[:min(len(downloading), dashboardMaxTorrents)] {
+		torrent := &downloading[i]
+
+		fmt.Fprintf(&text, "%s %.0f%% %s (%s)\n↓ %s/s, ETA: %s\n",
+			progressBar(torrent.PercentDone),
+			torrent.PercentDone*percentMultiply,
+			torrent.Name,
+			b.torrentRef(torrent),
+			formatBytes(torrent.DownloadRate),
+			formatDuration(torrent.ETA),
+		)
+	}
+
+	if hidden := len(downloading) - dashboardMaxTorrents; hidden > 0 {
+		fmt.Fprintf(&text, "…and %d more.", hidden)
+	}
+
+	return strings.TrimSpace(text.String())
+}
+
+// writeInstanceStatus writes the speeds, alt-speed state and free space of
+// an instance and returns its active downloads.
+func (b *Bot) writeInstanceStatus(ctx context.Context, text *strings.Builder, client *transmission.Client) []transmission.Torrent {
+	if b.multiInstance() {
+		text.WriteString(client.Name() + ":\n")
+	}
+
+	stats, err := client.SessionStats(ctx)
+	if err != nil {
+		b.logger.Debug("failed to get session stats for dashboard", "error", err, "instance", client.Name())
+		text.WriteString(userError("Failed to get the status", err) + "\n")
+
+		return nil
+	}
+
+	fmt.Fprintf(text, "Speed: ↓ %s/s ↑ %s/s\n", formatBytes(stats.DownloadSpeed), formatBytes(stats.UploadSpeed))
+
+	session, err := client.Session(ctx)
+	if err == nil {
+		fmt.Fprintf(text, "Alt-speed: %s\n", onOff(session.AltSpeedEnabled))
+
+		free, freeErr := client.FreeSpace(ctx, session.DownloadDir)
+		if freeErr == nil {
+			fmt.Fprintf(text, "Free space: %s\n", formatBytes(free))
+		}
+	}
+
+	torrents, err := client.ListTorrents(ctx)
+	if err != nil {
+		b.logger.Debug("failed to list torrents for dashboard", "error", err, "instance", client.Name())
+
+		return nil
+	}
+
+	var downloading []transmission.Torrent
+
+	for i := range torrents {
+		if torrents[i].Downloading() {
+			downloading = append(downloading, torrents[i])
+		}
+	}
+
+	return downloading
+}
+
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+
+	return "off"
+}
+
+// pin pins a message silently, through the sender queue.
+func (b *Bot) pin(chatID int64, messageID int) error {
+	pin := tgbotapi.PinChatMessageConfig{ChatID: chatID, MessageID: messageID, DisableNotification: true}
+
+	return b.sender.do(chatID, func() error {
+		_, err := b.api.Request(pin)
+		if err != nil {
+			return fmt.Errorf("pinning message: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// deleteMessage deletes a message the bot sent, which also unpins it. Failures
+// are only logged: the message may already be gone.
+func (b *Bot) deleteMessage(chatID int64, messageID int) {
+	err := b.sender.do(chatID, func() error {
+		_, err := b.api.Request(tgbotapi.NewDeleteMessage(chatID, messageID))
+		if err != nil {
+			return fmt.Errorf("deleting message: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		b.logger.Debug("failed to delete message", "error", err, "chat_id", chatID, "message_id", messageID)
+	}
+}
+
+// isNotModified reports whether an edit was rejected because the text did not change.
+func isNotModified(err error) bool {
+	var apiErr *tgbotapi.Error
+
+	return errors.As(err, &apiErr) && strings.Contains(apiErr.Message, "message is not modified")
+}
+
+// isMessageGone reports whether an edit failed because the message was
+// deleted or the bot can no longer write to the chat.
+func isMessageGone(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.Code == http.StatusForbidden ||
+		strings.Contains(apiErr.Message, "message to edit not found") ||
+		strings.Contains(apiErr.Message, "chat not found")
+}
@@ -0,0 +1,406 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+	"github.com/lexfrei/transmission-bot/internal/store"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// digestMaxTorrents caps each list of a digest.
+const digestMaxTorrents = 10
+
+const digestUsage = `Usage:
+/digest daily 09:00 [timezone]
+/digest weekly mon 09:00 [timezone]
+/digest off
+/digest now
+
+Timezones are names such as Europe/Berlin; without one the previous timezone or UTC is kept.`
+
+var (
+	errDigestUsage    = errors.New("invalid digest arguments")
+	errDigestTime     = errors.New("invalid time of day")
+	errDigestWeekday  = errors.New("invalid weekday")
+	errDigestTimezone = errors.New("unknown timezone")
+)
+
+// handleDigest shows, changes or sends the periodic summary of the user.
+// Digests are delivered in the user's private chat with the bot.
+func (b *Bot) handleDigest(ctx context.Context, msg *tgbotapi.Message) {
+	args := strings.Fields(strings.ToLower(msg.CommandArguments()))
+	current := b.store.Preferences(msg.From.ID).Digest
+
+	switch {
+	case len(args) == 0:
+		if current == nil {
+			b.reply(msg, "Digests are off.\n\n"+digestUsage)
+		} else {
+			b.reply(msg, "Your digest: "+describeDigest(current)+".\n\n"+digestUsage)
+		}
+
+		return
+	case args[0] == "now":
+		frequency := store.DigestDaily
+		if current != nil {
+			frequency = current.Frequency
+		}
+
+		b.reply(msg, b.renderDigest(b.digestSnapshot(ctx), current, frequency, time.Now()))
+
+		return
+	}
+
+	digest, err := parseDigest(strings.Fields(msg.CommandArguments()), current)
+	if err != nil {
+		b.reply(msg, digestError(err))
+
+		return
+	}
+
+	updateErr := b.store.UpdatePreferences(msg.From.ID, func(prefs *store.Preferences) {
+		// A new schedule keeps counting traffic from the last digest sent.
+		if digest != nil && prefs.Digest != nil {
+			digest.Baseline = prefs.Digest.Baseline
+		}
+
+		prefs.Digest = digest
+	})
+	if updateErr != nil {
+		b.logger.Error("failed to save digest settings", "error", updateErr, "user_id", msg.From.ID)
+		b.reply(msg, "Failed to save your digest settings. Please try again.")
+
+		return
+	}
+
+	if digest == nil {
+		b.reply(msg, "Digests are off.")
+
+		return
+	}
+
+	b.reply(msg, fmt.Sprintf("You'll get a digest %s in a private chat with me.", describeDigest(digest)))
+}
+
+// parseDigest reads the arguments of /digest: "off", "daily HH:MM [zone]"
+// or "weekly DAY HH:MM [zone]". Without a zone, current's zone is kept. A nil
+// digest turns digests off.
+func parseDigest(args []string, current *store.Digest) (*store.Digest, error) {
+	if len(args) == 1 && strings.EqualFold(args[0], "off") {
+		return nil, nil //nolint:nilnil // nil turns digests off
+	}
+
+	digest := &store.Digest{Frequency: strings.ToLower(args[0]), Timezone: time.UTC.String()}
+	if current != nil {
+		digest.Timezone = current.Timezone
+	}
+
+	rest := args[1:]
+
+	switch digest.Frequency {
+	case store.DigestDaily:
+	case store.DigestWeekly:
+		if len(rest) == 0 {
+			return nil, errDigestUsage
+		}
+
+		weekday, ok := config.ParseWeekday(rest[0])
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", errDigestWeekday, rest[0])
+		}
+
+		digest.Weekday = weekday
+		rest = rest[1:]
+	default:
+		return nil, errDigestUsage
+	}
+
+	if len(rest) == 0 || len(rest) > 2 {
+		return nil, errDigestUsage
+	}
+
+	clock, err := config.ParseClock(rest[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errDigestTime, err)
+	}
+
+	digest.Hour, digest.Minute = clock/config.MinutesPerHour, clock%config.MinutesPerHour
+
+	if len(rest) == 2 {
+		digest.Timezone = rest[1]
+	}
+
+	_, specErr := digestSpec(digest)
+	if specErr != nil {
+		return nil, specErr
+	}
+
+	return digest, nil
+}
+
+// digestSpec returns when a digest is due.
+func digestSpec(digest *store.Digest) (cronSpec, error) {
+	location, err := time.LoadLocation(digest.Timezone)
+	if err != nil {
+		return cronSpec{}, fmt.Errorf("%w: %q", errDigestTimezone, digest.Timezone)
+	}
+
+	spec := cronSpec{minute: digest.Minute, hour: digest.Hour, weekday: anyWeekday, location: location}
+	if digest.Frequency == store.DigestWeekly {
+		spec.weekday = digest.Weekday
+	}
+
+	return spec, nil
+}
+
+func describeDigest(digest *store.Digest) string {
+	clock := fmt.Sprintf("%02d:%02d (%s)", digest.Hour, digest.Minute, digest.Timezone)
+
+	if digest.Frequency == store.DigestWeekly {
+		return fmt.Sprintf("weekly on %s at %s", digest.Weekday, clock)
+	}
+
+	return "daily at " + clock
+}
+
+func digestError(err error) string {
+	switch {
+	case errors.Is(err, errDigestTime):
+		return "Invalid time: please use HH:MM, like 09:00."
+	case errors.Is(err, errDigestWeekday):
+		return "Invalid day: please use mon, tue, wed, thu, fri, sat or sun."
+	case errors.Is(err, errDigestTimezone):
+		return "Unknown timezone: please use a name such as Europe/Berlin or UTC."
+	default:
+		return digestUsage
+	}
+}
+
+// sendDueDigests is the scheduler job that delivers the digests due at minute.
+func (b *Bot) sendDueDigests(ctx context.Context, minute time.Time) {
+	due := make(map[int64]store.Preferences)
+
+	for userID, prefs := range b.store.AllPreferences() {
+		if prefs.Digest == nil || !b.can(userID, permView) || slices.Contains(prefs.Notifications.Muted, eventDigest) {
+			continue
+		}
+
+		// A bot can't start a private chat; wait until the user has written in one.
+		if user, known := b.store.User(userID); !known || !user.PrivateChat {
+			continue
+		}
+
+		spec, err := digestSpec(prefs.Digest)
+		if err != nil {
+			b.logger.Warn("invalid digest settings", "error", err, "user_id", userID)
+
+			continue
+		}
+
+		if spec.matches(minute) {
+			due[userID] = prefs
+		}
+	}
+
+	if len(due) == 0 {
+		return
+	}
+
+	// Collecting the digest takes RPCs; don't hold up the scheduler.
+	b.background.start(ctx, func(ctx context.Context) {
+		snapshot := b.digestSnapshot(ctx)
+
+		// Shutting down: the snapshot would only list failures.
+		if ctx.Err() != nil {
+			return
+		}
+
+		for userID, prefs := range due {
+			b.deliver(userID, prefs.Notifications.Quiet,
+				b.renderDigest(snapshot, prefs.Digest, prefs.Digest.Frequency, minute))
+			b.saveDigestBaseline(userID, snapshot.transfers)
+		}
+	})
+}
+
+// saveDigestBaseline records the traffic a digest was sent at, so that the
+// next one reports only what was transferred since. Instances missing from
+// transfers keep their previous baseline.
+func (b *Bot) saveDigestBaseline(userID int64, transfers map[string]store.Transfer) {
+	updateErr := b.store.UpdatePreferences(userID, func(prefs *store.Preferences) {
+		if prefs.Digest == nil {
+			return
+		}
+
+		baseline := make(map[string]store.Transfer, len(transfers))
+		maps.Copy(baseline, prefs.Digest.Baseline)
+		maps.Copy(baseline, transfers)
+		prefs.Digest.Baseline = baseline
+	})
+	if updateErr != nil {
+		b.logger.Error("failed to save digest baseline", "error", updateErr, "user_id", userID)
+	}
+}
+
+// digestSnapshot is what a digest reports, collected once for every
+// recipient of the same minute.
+type digestSnapshot struct {
+	torrents []transmission.Torrent
+	failures []string
+	// transfers is the cumulative traffic of each instance that answered, by name.
+	transfers map[string]store.Transfer
+	// free is the free space of each instance's download directory, in instance order.
+	free []string
+}
+
+func (b *Bot) digestSnapshot(ctx context.Context) *digestSnapshot {
+	snapshot := &digestSnapshot{transfers: make(map[string]store.Transfer, len(b.instances))}
+	snapshot.torrents, snapshot.failures = b.collectTorrents(ctx, b.instances)
+
+	for _, client := range b.instances {
+		stats, err := client.SessionStats(ctx)
+		if err == nil {
+			snapshot.transfers[client.Name()] = store.Transfer{
+				Downloaded: stats.DownloadedBytes,
+				Uploaded:   stats.UploadedBytes,
+			}
+		}
+
+		session, err := client.Session(ctx)
+		if err != nil {
+			continue
+		}
+
+		free, err := client.FreeSpace(ctx, session.DownloadDir)
+		if err != nil {
+			continue
+		}
+
+		line := formatBytes(free)
+		if b.multiInstance() {
+			line = client.Name() + ": " + line
+		}
+
+		snapshot.free = append(snapshot.free, line)
+	}
+
+	return snapshot
+}
+
+// renderDigest summarizes what completed in the digest's period up to now,
+// what is still downloading, the traffic and ratio since the last digest, and
+// free space. digest may be nil, as for /digest now without a schedule.
+func (b *Bot) renderDigest(snapshot *digestSnapshot, digest *store.Digest, frequency string, now time.Time) string {
+	title, period, since := "Daily digest", "day", now.AddDate(0, 0, -1)
+	if frequency == store.DigestWeekly {
+		title, period, since = "Weekly digest", "week", now.AddDate(0, 0, -7)
+	}
+
+	var completed, downloading []string
+
+	for i := range snapshot.torrents {
+		torrent := &snapshot.torrents[i]
+
+		switch {
+		case torrent.Complete() && torrent.DoneAt.After(since):
+			completed = append(completed, fmt.Sprintf("• %s (%s)", torrent.Name, formatBytes(torrent.TotalSize)))
+		case !torrent.Complete() && !torrent.Paused():
+			downloading = append(downloading, fmt.Sprintf("• %.0f%% %s, ETA: %s",
+				torrent.PercentDone*percentMultiply, torrent.Name, formatDuration(torrent.ETA)))
+		}
+	}
+
+	var text strings.Builder
+
+	text.WriteString(title + "\n\n")
+	writeDigestList(&text, "Completed in the last "+period, completed)
+	writeDigestList(&text, "Still downloading", downloading)
+
+	var baseline map[string]store.Transfer
+	if digest != nil {
+		baseline = digest.Baseline
+	}
+
+	transferred, known := transferSince(snapshot.transfers, baseline)
+	if known {
+		fmt.Fprintf(&text, "Transferred since the last digest: ↓ %s ↑ %s\n",
+			formatBytes(transferred.Downloaded), formatBytes(transferred.Uploaded))
+
+		if transferred.Downloaded > 0 {
+			fmt.Fprintf(&text, "Ratio: %.2f\n", float64(transferred.Uploaded)/float64(transferred.Downloaded))
+		}
+	} else {
+		text.WriteString("Transferred: shown from the next scheduled digest on\n")
+	}
+
+	if len(snapshot.free) > 0 {
+		text.WriteString("Free space: " + strings.Join(snapshot.free, ", ") + "\n")
+	}
+
+	if len(snapshot.failures) > 0 {
+		text.WriteString("\n" + strings.Join(snapshot.failures, "\n"))
+	}
+
+	return strings.TrimSpace(text.String())
+}
+
+// transferSince returns the traffic of all instances since baseline. known is
+// false when no instance has a baseline yet. Counters that went down were
+// reset in Transmission, so everything since the reset is counted.
+func transferSince(current, baseline map[string]store.Transfer) (store.Transfer, bool) {
+	var (
+		total store.Transfer
+		known bool
+	)
+
+	for name, now := range current {
+		before, ok := baseline[name]
+		if !ok {
+			continue
+		}
+
+		known = true
+		total.Downloaded += counterSince(now.Downloaded, before.Downloaded)
+		total.Uploaded += counterSince(now.Uploaded, before.Uploaded)
+	}
+
+	return total, known
+}
+
+func counterSince(now, before int64) int64 {
+	if now < before {
+		return now
+	}
+
+	return now - before
+}
+
+// writeDigestList writes a titled list of at most digestMaxTorrents lines.
+func writeDigestList(text *strings.Builder, title string, lines []string) {
+	if len(lines) == 0 {
+		fmt.Fprintf(text, "%s: none\n\n", title)
+
+		return
+	}
+
+	fmt.Fprintf(text, "%s (%d):\n", title, len(lines))
+
+	for _, line := range lines[:min(len(lines), digestMaxTorrents)] {
+		text.WriteString(line + "\n")
+	}
+
+	if hidden := len(lines) - digestMaxTorrents; hidden > 0 {
+		fmt.Fprintf(text, "…and %d more.\n", hidden)
+	}
+
+	text.WriteString("\n")
+}
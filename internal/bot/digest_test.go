@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // the tests load named zones
+
+	"github.com/lexfrei/transmission-bot/internal/store"
+)
+
+func TestParseDigest(t *testing.T) {
+	t.Parallel()
+
+	berlin := &store.Digest{Frequency: store.DigestDaily, Hour: 8, Timezone: "Europe/Berlin"}
+
+	tests := []struct {
+		name    string
+		args    string
+		current *store.Digest
+		want    *store.Digest
+		wantErr error
+	}{
+		{name: "off", args: "OFF"},
+		{name: "daily in UTC", args: "daily 09:30",
+			want: &store.Digest{Frequency: store.DigestDaily, Hour: 9, Minute: 30, Timezone: "UTC"}},
+		{name: "daily keeps the previous zone", args: "Daily 07:05", current: berlin,
+			want: &store.Digest{Frequency: store.DigestDaily, Hour: 7, Minute: 5, Timezone: "Europe/Berlin"}},
+		{name: "weekly with zone", args: "weekly Friday 18:00 Asia/Tokyo",
+			want: &store.Digest{Frequency: store.DigestWeekly, Weekday: time.Friday, Hour: 18, Timezone: "Asia/Tokyo"}},
+		{name: "weekly without day", args: "weekly", wantErr: errDigestUsage},
+		{name: "weekly with bad day", args: "weekly someday 09:00", wantErr: errDigestWeekday},
+		{name: "unknown frequency", args: "hourly 09:00", wantErr: errDigestUsage},
+		{name: "missing time", args: "daily", wantErr: errDigestUsage},
+		{name: "extra arguments", args: "daily 09:00 UTC extra", wantErr: errDigestUsage},
+		{name: "bad time", args: "daily 25:00", wantErr: errDigestTime},
+		{name: "bad zone", args: "daily 09:00 Mars/Olympus", wantErr: errDigestTimezone},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseDigest(strings.Fields(test.args), test.current)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("parseDigest(%q) error = %v, want %v", test.args, err, test.wantErr)
+			}
+
+			if !sameDigest(got, test.want) {
+				t.Errorf("parseDigest(%q) = %+v, want %+v", test.args, got, test.want)
+			}
+		})
+	}
+}
+
+func sameDigest(got, want *store.Digest) bool {
+	if got == nil || want == nil {
+		return got == want
+	}
+
+	return got.Frequency == want.Frequency && got.Weekday == want.Weekday && got.Hour == want.Hour &&
+		got.Minute == want.Minute && got.Timezone == want.Timezone
+}
+
+func TestCronSpecMatches(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2026-03-02 is a Monday; Berlin is at UTC+1 then.
+	monday := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		spec   cronSpec
+		minute time.Time
+		want   bool
+	}{
+		{name: "daily at the minute", spec: cronSpec{hour: 8, weekday: anyWeekday, location: time.UTC},
+			minute: monday, want: true},
+		{name: "daily a minute later", spec: cronSpec{hour: 8, weekday: anyWeekday, location: time.UTC},
+			minute: monday.Add(time.Minute)},
+		{name: "daily in another zone", spec: cronSpec{hour: 9, weekday: anyWeekday, location: berlin},
+			minute: monday, want: true},
+		{name: "zone hour differs from UTC hour", spec: cronSpec{hour: 8, weekday: anyWeekday, location: berlin},
+			minute: monday},
+		{name: "weekly on its day", spec: cronSpec{hour: 8, weekday: time.Monday, location: time.UTC},
+			minute: monday, want: true},
+		{name: "weekly on another day", spec: cronSpec{hour: 8, weekday: time.Tuesday, location: time.UTC},
+			minute: monday},
+		{name: "weekday taken in the zone", spec: cronSpec{hour: 0, minute: 30, weekday: time.Tuesday, location: berlin},
+			minute: time.Date(2026, 3, 2, 23, 30, 0, 0, time.UTC), want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := test.spec.matches(test.minute); got != test.want {
+				t.Errorf("matches(%s) = %v, want %v", test.minute, got, test.want)
+			}
+		})
+	}
+}
+
+func TestTransferSince(t *testing.T) {
+	t.Parallel()
+
+	current := map[string]store.Transfer{
+		"nas":     {Downloaded: 1500, Uploaded: 900},
+		"seedbox": {Downloaded: 200, Uploaded: 50},
+	}
+
+	tests := []struct {
+		name      string
+		baseline  map[string]store.Transfer
+		want      store.Transfer
+		wantKnown bool
+	}{
+		{name: "no baseline"},
+		{name: "period difference", baseline: map[string]store.Transfer{
+			"nas": {Downloaded: 1000, Uploaded: 400}, "seedbox": {Downloaded: 100, Uploaded: 50},
+		}, want: store.Transfer{Downloaded: 600, Uploaded: 500}, wantKnown: true},
+		{name: "counters reset", baseline: map[string]store.Transfer{
+			"nas": {Downloaded: 5000, Uploaded: 100},
+		}, want: store.Transfer{Downloaded: 1500, Uploaded: 800}, wantKnown: true},
+		{name: "removed instance is ignored", baseline: map[string]store.Transfer{
+			"old": {Downloaded: 1}, "seedbox": {Downloaded: 200, Uploaded: 50},
+		}, wantKnown: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, known := transferSince(current, test.baseline)
+			if got != test.want || known != test.wantKnown {
+				t.Errorf("transferSince() = %+v, %v; want %+v, %v", got, known, test.want, test.wantKnown)
+			}
+		})
+	}
+}
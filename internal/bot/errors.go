@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"errors"
+
+	"github.com/lexfrei/transmission-bot/internal/bencode"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+const (
+	msgTransmissionUnavailable = "Transmission is not reachable right now. Please try again in a few minutes."
+	msgTorrentNotFound         = "Torrent not found."
+)
+
+// errorMessages maps domain errors from the transmission and bencode packages
+// to what the user is told. Checked in order; the first match wins.
+//
+//nolint:gochecknoglobals // static lookup table
+var errorMessages = []struct {
+	err     error
+	message string
+}{
+	{transmission.ErrUnauthorized, "Transmission rejected the bot's credentials. Please ask an admin to check the configuration."},
+	{transmission.ErrSessionConflict, "Transmission didn't accept the session. Please try again."},
+	{transmission.ErrTimeout, "Transmission took too long to respond. Please try again later."},
+	{transmission.ErrConnectionRefused, msgTransmissionUnavailable},
+	{transmission.ErrUnavailable, msgTransmissionUnavailable},
+	{transmission.ErrInvalidTorrent, "This isn't a valid torrent file or magnet link."},
+	{transmission.ErrTorrentNotFound, msgTorrentNotFound},
+	{bencode.ErrMissingInfo, "This isn't a torrent file: it has no info section."},
+	{bencode.ErrMalformed, "This file is damaged or isn't a torrent file."},
+}
+
+// userError turns an error from Transmission into a message fit for chat.
+// The details stay in the logs; action describes what failed, as in
+// "Failed to list torrents", and is used for errors without a specific message.
+func userError(action string, err error) string {
+	for _, known := range errorMessages {
+		if errors.Is(err, known.err) {
+			return known.message
+		}
+	}
+
+	return action + ". Please try again later."
+}
@@ -0,0 +1,163 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// torrentState is what the notification watcher last saw of a torrent.
+type torrentState struct {
+	complete bool
+	failed   bool
+	// stalledSince is when the download last stopped receiving data; zero
+	// while it is receiving or not downloading.
+	stalledSince  time.Time
+	stallReported bool
+}
+
+// watchNotifications checks every instance periodically for completed,
+// failed and stalled downloads and for low disk space, and notifies users.
+// Torrents are compared with the previous check, so nothing is reported for
+// torrents as they are on the first check of each instance after startup.
+func (b *Bot) watchNotifications(ctx context.Context) {
+	ticker := time.NewTicker(b.notifier.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, client := range b.instances {
+				b.checkTorrents(ctx, client, now)
+				b.checkDiskSpace(ctx, client)
+			}
+		}
+	}
+}
+
+// checkTorrents reports the torrents of an instance that completed, failed
+// or stalled since the previous check. An instance that can't be listed
+// keeps its previous state, so changes are reported once it is back.
+func (b *Bot) checkTorrents(ctx context.Context, client *transmission.Client, now time.Time) {
+	torrents, err := client.ListTorrents(ctx)
+	if err != nil {
+		b.logger.Debug("failed to list torrents for notifications", "error", err, "instance", client.Name())
+
+		return
+	}
+
+	previous, polled := b.notifier.torrents[client.Name()]
+	current := make(map[string]*torrentState, len(torrents))
+
+	for i := range torrents {
+		torrent := &torrents[i]
+
+		// The first check only takes stock. After that, a new torrent is seen
+		// as incomplete and healthy, so that one which completed or failed
+		// between two checks is still reported.
+		state, known := previous[torrent.Hash]
+		if !known {
+			state = &torrentState{}
+			if !polled {
+				state.complete, state.failed = torrent.Complete(), torrent.Error != ""
+			}
+		}
+
+		for _, note := range b.torrentEvents(state, torrent, now) {
+			b.notify(note)
+		}
+
+		current[torrent.Hash] = state
+	}
+
+	b.notifier.torrents[client.Name()] = current
+}
+
+// torrentEvents updates the state of a torrent and returns what changed.
+func (b *Bot) torrentEvents(state *torrentState, torrent *transmission.Torrent, now time.Time) []notification {
+	var notes []notification
+
+	if !state.complete && torrent.Complete() {
+		notes = append(notes, notification{
+			event:   eventCompletion,
+			torrent: torrent,
+			text:    b.cardHeader("Download complete:", torrent) + "\nSize: " + formatBytes(torrent.TotalSize),
+		})
+	}
+
+	if !state.failed && torrent.Error != "" {
+		notes = append(notes, notification{
+			event:   eventErrors,
+			torrent: torrent,
+			text:    b.cardHeader("Download failed:", torrent) + "\nError: " + torrent.Error,
+		})
+	}
+
+	state.complete = torrent.Complete()
+	state.failed = torrent.Error != ""
+
+	if !torrent.Downloading() || torrent.DownloadRate > 0 {
+		state.stalledSince, state.stallReported = time.Time{}, false
+
+		return notes
+	}
+
+	if state.stalledSince.IsZero() {
+		state.stalledSince = now
+	}
+
+	if !state.stallReported && now.Sub(state.stalledSince) >= b.notifier.stallAfter {
+		state.stallReported = true
+
+		notes = append(notes, notification{
+			event:   eventStalls,
+			torrent: torrent,
+			text: b.cardHeader("Download stalled:", torrent) +
+				fmt.Sprintf("\nNothing received for %s, %s", formatDuration(b.notifier.stallAfter), torrentStatus(torrent)),
+		})
+	}
+
+	return notes
+}
+
+// checkDiskSpace alerts once when the free space of an instance's download
+// directory drops below the configured minimum, and again only after it has
+// recovered.
+func (b *Bot) checkDiskSpace(ctx context.Context, client *transmission.Client) {
+	if b.notifier.minFreeSpace == 0 {
+		return
+	}
+
+	session, err := client.Session(ctx)
+	if err != nil {
+		b.logger.Debug("failed to get session for notifications", "error", err, "instance", client.Name())
+
+		return
+	}
+
+	free, err := client.FreeSpace(ctx, session.DownloadDir)
+	if err != nil {
+		b.logger.Debug("failed to get free space for notifications", "error", err, "instance", client.Name())
+
+		return
+	}
+
+	low := free < b.notifier.minFreeSpace
+	wasLow := b.notifier.lowDisk[client.Name()]
+	b.notifier.lowDisk[client.Name()] = low
+
+	if !low || wasLow {
+		return
+	}
+
+	text := fmt.Sprintf("Low disk space: %s free in %s", formatBytes(free), session.DownloadDir)
+	if b.multiInstance() {
+		text = client.Name() + ": " + text
+	}
+
+	b.notify(notification{event: eventDisk, text: text})
+}
@@ -0,0 +1,232 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lexfrei/transmission-bot/internal/bencode"
+	"github.com/lexfrei/transmission-bot/internal/config"
+)
+
+// maxFetchRedirects bounds how many redirects a .torrent download follows.
+const maxFetchRedirects = 10
+
+var (
+	errFetchTooLarge = errors.New("file too large")
+	errFetchNotFile  = errors.New("not a torrent file")
+	errFetchBlocked  = errors.New("address not allowed")
+)
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which netip
+// does not count as private.
+//
+//nolint:gochecknoglobals // static address range
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// fetchStatusError is returned when a download is answered with a status other than 200 OK.
+type fetchStatusError struct {
+	status string
+}
+
+func (e *fetchStatusError) Error() string {
+	return "unexpected HTTP status: " + e.status
+}
+
+// torrentContentTypes are the content types accepted for .torrent downloads.
+// Many trackers serve them as generic binary data.
+//
+//nolint:gochecknoglobals // static lookup table
+var torrentContentTypes = map[string]struct{}{
+	"application/x-bittorrent": {},
+	"application/octet-stream": {},
+}
+
+// torrentFetcher downloads .torrent files linked in messages, so that links
+// behind private tracker cookies work and Transmission never sees the URL.
+type torrentFetcher struct {
+	client  *http.Client
+	maxSize int64
+	cookies map[string]string
+}
+
+func newTorrentFetcher(cfg config.FetchConfig) *torrentFetcher {
+	cookies := make(map[string]string, len(cfg.Cookies))
+	for domain, cookie := range cfg.Cookies {
+		cookies[strings.ToLower(strings.TrimPrefix(domain, "."))] = cookie
+	}
+
+	return &torrentFetcher{
+		client:  newFetchClient(cfg.Timeout),
+		maxSize: cfg.MaxSize,
+		cookies: cookies,
+	}
+}
+
+// newFetchClient returns the HTTP client for .torrent downloads. Links come
+// from chat users, so every connection, including redirects, is checked after
+// DNS resolution and refused when it would reach the bot's own host or network.
+// Proxies from the environment are not used, since the check would only see
+// the proxy's address.
+func newFetchClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Control: guardDial}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // always an *http.Transport
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     transport,
+		CheckRedirect: checkFetchRedirect,
+	}
+}
+
+// guardDial refuses connections to addresses a link must not reach. It runs
+// for every resolved address the dialer tries.
+func guardDial(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errFetchBlocked, address)
+	}
+
+	if blockedAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", errFetchBlocked, addrPort.Addr())
+	}
+
+	return nil
+}
+
+// blockedAddress reports whether ip is loopback, private, link-local (which
+// includes cloud metadata services), unspecified or multicast.
+func blockedAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() ||
+		ip.IsMulticast() || sharedAddressSpace.Contains(ip)
+}
+
+// checkFetchRedirect follows only http(s) redirects, up to maxFetchRedirects.
+func checkFetchRedirect(req *http.Request, via []*http.Request) error {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("%w: redirect to %s", errFetchBlocked, req.URL.Scheme)
+	}
+
+	if len(via) >= maxFetchRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+	}
+
+	return nil
+}
+
+// fetch downloads the .torrent file at rawURL. A response without a content
+// type is only accepted when it parses as a torrent.
+func (f *torrentFetcher) fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	if cookie := f.cookieFor(req.URL.Hostname()); cookie != "" {
+		req.Header.Set("Cookie", cookie)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading torrent: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &fetchStatusError{status: resp.Status}
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" {
+		mediaType, _, parseErr := mime.ParseMediaType(contentType)
+		if _, ok := torrentContentTypes[mediaType]; parseErr != nil || !ok {
+			return nil, fmt.Errorf("%w: served as %s", errFetchNotFile, contentType)
+		}
+	}
+
+	if resp.ContentLength > f.maxSize {
+		return nil, fmt.Errorf("%w: %d bytes", errFetchTooLarge, resp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading torrent: %w", err)
+	}
+
+	if int64(len(data)) > f.maxSize {
+		return nil, fmt.Errorf("%w: over %d bytes", errFetchTooLarge, f.maxSize)
+	}
+
+	if contentType == "" {
+		validateErr := bencode.ValidateTorrent(data)
+		if validateErr != nil {
+			return nil, fmt.Errorf("%w: no content type and %w", errFetchNotFile, validateErr)
+		}
+	}
+
+	return data, nil
+}
+
+// cookieFor returns the configured cookie of the most specific domain that
+// host belongs to, or an empty string.
+func (f *torrentFetcher) cookieFor(host string) string {
+	host = strings.ToLower(host)
+
+	var matched, cookie string
+
+	for domain, value := range f.cookies {
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			continue
+		}
+
+		if len(domain) > len(matched) {
+			matched, cookie = domain, value
+		}
+	}
+
+	return cookie
+}
+
+// fetchError explains to the user why a .torrent link could not be downloaded.
+func (f *torrentFetcher) fetchError(err error) string {
+	var statusErr *fetchStatusError
+
+	switch {
+	case errors.As(err, &statusErr):
+		return "the server answered " + statusErr.status
+	case errors.Is(err, errFetchTooLarge):
+		return fmt.Sprintf("the file is larger than %s", formatBytes(f.maxSize))
+	case errors.Is(err, errFetchNotFile):
+		return "the link doesn't lead to a .torrent file (a login page?)"
+	case errors.Is(err, errFetchBlocked):
+		return "the link points to a private or local address"
+	default:
+		return "the download failed"
+	}
+}
+
+// displayURL shortens a link for chat replies, dropping the query and
+// fragment, where trackers put passkeys.
+func displayURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return parsed.Host + parsed.Path
+}
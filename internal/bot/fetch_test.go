@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+)
+
+const testTorrent = "d4:infod4:name4:testee"
+
+func TestBlockedAddress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "127.0.0.1", want: true},
+		{addr: "::1", want: true},
+		{addr: "10.1.2.3", want: true},
+		{addr: "172.16.0.1", want: true},
+		{addr: "192.168.1.10", want: true},
+		{addr: "169.254.169.254", want: true},
+		{addr: "100.64.0.1", want: true},
+		{addr: "0.0.0.0", want: true},
+		{addr: "fd00::1", want: true},
+		{addr: "fe80::1", want: true},
+		{addr: "::ffff:127.0.0.1", want: true},
+		{addr: "224.0.0.1", want: true},
+		{addr: "93.184.216.34", want: false},
+		{addr: "2606:4700::1111", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			t.Parallel()
+
+			if got := blockedAddress(netip.MustParseAddr(test.addr)); got != test.want {
+				t.Errorf("blockedAddress(%s) = %v, want %v", test.addr, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFetchRefusesLocalAddresses(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(testTorrent))
+	}))
+	defer server.Close()
+
+	fetcher := newTorrentFetcher(config.FetchConfig{MaxSize: 1024})
+
+	_, err := fetcher.fetch(t.Context(), server.URL+"/a.torrent")
+	if !errors.Is(err, errFetchBlocked) {
+		t.Errorf("fetch() error = %v, want %v", err, errFetchBlocked)
+	}
+}
+
+func TestCheckFetchRedirect(t *testing.T) {
+	t.Parallel()
+
+	redirect := httptest.NewRequest(http.MethodGet, "file:///etc/passwd", nil)
+
+	err := checkFetchRedirect(redirect, nil)
+	if !errors.Is(err, errFetchBlocked) {
+		t.Errorf("checkFetchRedirect(file) error = %v, want %v", err, errFetchBlocked)
+	}
+
+	via := make([]*http.Request, maxFetchRedirects)
+
+	err = checkFetchRedirect(httptest.NewRequest(http.MethodGet, "https://example.org/", nil), via)
+	if err == nil {
+		t.Error("checkFetchRedirect() followed too many redirects")
+	}
+}
+
+func TestFetchContentType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     error
+	}{
+		{name: "torrent type", contentType: "application/x-bittorrent", body: testTorrent},
+		{name: "binary type", contentType: "application/octet-stream", body: testTorrent},
+		{name: "html", contentType: "text/html; charset=utf-8", body: "<html>", wantErr: errFetchNotFile},
+		{name: "no type with torrent body", body: testTorrent},
+		{name: "no type with other body", body: "<html>login</html>", wantErr: errFetchNotFile},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				// An empty value stops net/http from sniffing a content type.
+				w.Header()["Content-Type"] = []string{test.contentType}
+				_, _ = w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			// The test server is on loopback, so use a client without the guard.
+			fetcher := newTorrentFetcher(config.FetchConfig{MaxSize: 1024})
+			fetcher.client = server.Client()
+
+			_, err := fetcher.fetch(t.Context(), server.URL+"/a.torrent")
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("fetch() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+)
+
+// threadKey identifies a message whose replies belong to a forum topic.
+type threadKey struct {
+	chatID    int64
+	messageID int
+}
+
+// threadIndex remembers the forum topic of messages while they are being handled,
+// so that replies land in the same thread.
+type threadIndex struct {
+	mu      sync.Mutex
+	threads map[threadKey]int
+}
+
+func newThreadIndex() *threadIndex {
+	return &threadIndex{threads: make(map[threadKey]int)}
+}
+
+func (t *threadIndex) remember(msg *tgbotapi.Message, threadID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.threads[threadKey{chatID: msg.Chat.ID, messageID: msg.MessageID}] = threadID
+}
+
+func (t *threadIndex) forget(msg *tgbotapi.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.threads, threadKey{chatID: msg.Chat.ID, messageID: msg.MessageID})
+}
+
+func (t *threadIndex) lookup(msg *tgbotapi.Message) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.threads[threadKey{chatID: msg.Chat.ID, messageID: msg.MessageID}]
+}
+
+// acceptGroupMessage reports whether a group message should be handled: the
+// chat must be allow-listed and, to avoid grabbing every link posted in the
+// group, non-command messages must mention the bot or reply to it.
+func (b *Bot) acceptGroupMessage(msg *tgbotapi.Message) bool {
+	if !b.chatAllowed(msg.Chat) {
+		b.logger.Warn("message from chat not in allowed_chats",
+			"chat_id", msg.Chat.ID,
+			"chat_title", msg.Chat.Title,
+		)
+
+		return false
+	}
+
+	if msg.IsCommand() {
+		return true
+	}
+
+	return b.mentionsMe(msg) || b.repliesToMe(msg) || b.albums.buffering(msg)
+}
+
+func (b *Bot) chatAllowed(chat *tgbotapi.Chat) bool {
+	if chat.IsPrivate() {
+		return true
+	}
+
+	_, ok := b.allowedChats[chat.ID]
+
+	return ok
+}
+
+// commandForMe reports whether a command is addressed to this bot rather than
+// to another bot in the same group (as in /list@otherbot).
+func (b *Bot) commandForMe(msg *tgbotapi.Message) bool {
+	_, target, addressed := strings.Cut(msg.CommandWithAt(), "@")
+
+	return !addressed || strings.EqualFold(target, b.api.Self.UserName)
+}
+
+func (b *Bot) mentionsMe(msg *tgbotapi.Message) bool {
+	mention := "@" + strings.ToLower(b.api.Self.UserName)
+
+	if strings.Contains(strings.ToLower(msg.Text), mention) ||
+		strings.Contains(strings.ToLower(msg.Caption), mention) {
+		return true
+	}
+
+	for _, entities := range [][]tgbotapi.MessageEntity{msg.Entities, msg.CaptionEntities} {
+		for _, entity := range entities {
+			if entity.Type == "text_mention" && entity.User != nil && entity.User.ID == b.api.Self.ID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// withoutMention drops mentions of the bot from text, so that "@bot <hash>"
+// in a group is read like a bare hash in a private chat.
+func (b *Bot) withoutMention(text string) string {
+	mention := "@" + strings.ToLower(b.api.Self.UserName)
+
+	words := strings.Fields(text)
+	kept := words[:0]
+
+	for _, word := range words {
+		if strings.ToLower(word) != mention {
+			kept = append(kept, word)
+		}
+	}
+
+	return strings.Join(kept, " ")
+}
+
+func (b *Bot) repliesToMe(msg *tgbotapi.Message) bool {
+	return msg.ReplyToMessage != nil &&
+		msg.ReplyToMessage.From != nil &&
+		msg.ReplyToMessage.From.ID == b.api.Self.ID
+}
+
+// registerChatCommands shows the viewer menu in every allowed group. A group
+// menu is shared by all its members, so it lists only what every role may run.
+func (b *Bot) registerChatCommands() {
+	for chatID := range b.allowedChats {
+		chatMenu := tgbotapi.NewSetMyCommandsWithScope(
+			tgbotapi.NewBotCommandScopeChat(chatID),
+			menuFor(config.RoleViewer)...,
+		)
+
+		_, err := b.api.Request(chatMenu)
+		if err != nil {
+			b.logger.Warn("failed to set chat commands", "error", err, "chat_id", chatID)
+		}
+	}
+}
+
+// sendToThread sends a message into a forum topic. tgbotapi v5.5.1 has no
+// message_thread_id field, so the request parameters are built by hand.
+func (b *Bot) sendToThread(cfg tgbotapi.MessageConfig, threadID int) (tgbotapi.Message, error) {
+	params := tgbotapi.Params{
+		"chat_id":           strconv.FormatInt(cfg.ChatID, 10),
+		"message_thread_id": strconv.Itoa(threadID),
+		"text":              cfg.Text,
+	}
+	params.AddNonZero("reply_to_message_id", cfg.ReplyToMessageID)
+	params.AddBool("disable_notification", cfg.DisableNotification)
+	params.AddBool("allow_sending_without_reply", cfg.AllowSendingWithoutReply)
+	params.AddBool("disable_web_page_preview", cfg.DisableWebPagePreview)
+	params.AddNonEmpty("parse_mode", cfg.ParseMode)
+
+	markupErr := params.AddInterface("reply_markup", cfg.ReplyMarkup)
+	if markupErr != nil {
+		return tgbotapi.Message{}, fmt.Errorf("encoding reply markup: %w", markupErr)
+	}
+
+	resp, err := b.api.MakeRequest("sendMessage", params)
+	if err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("sending message to thread: %w", err)
+	}
+
+	var sent tgbotapi.Message
+
+	decodeErr := json.Unmarshal(resp.Result, &sent)
+	if decodeErr != nil {
+		return tgbotapi.Message{}, fmt.Errorf("decoding sent message: %w", decodeErr)
+	}
+
+	return sent, nil
+}
@@ -3,10 +3,13 @@ package bot
 import (
 	"context"
 	"fmt"
-	"strconv"
+	"slices"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/transmission"
 )
 
 const (
@@ -14,7 +17,30 @@ const (
 	maxMessageLength = 4096
 )
 
+const msgNotPermitted = "You don't have permission to do that."
+
 func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
+	perm, known := commandPermission(msg.Command())
+
+	commandLabel := msg.Command()
+	if !known {
+		commandLabel = "unknown"
+	}
+
+	defer func(start time.Time) {
+		b.metrics.ObserveCommand(commandLabel, time.Since(start))
+	}(time.Now())
+
+	if known && !b.can(msg.From.ID, perm) {
+		b.logger.Warn("command not permitted",
+			"user_id", msg.From.ID,
+			"command", msg.Command(),
+		)
+		b.reply(msg, msgNotPermitted)
+
+		return
+	}
+
 	switch msg.Command() {
 	case "start":
 		b.handleStart(msg)
@@ -22,8 +48,32 @@ func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
 		b.handleHelp(msg)
 	case "list":
 		b.handleList(ctx, msg)
+	case "mine":
+		b.handleMine(ctx, msg)
+	case "stats":
+		b.handleStats(ctx, msg)
+	case "use":
+		b.handleUse(msg)
+	case "dashboard":
+		b.handleDashboard(ctx, msg)
+	case "digest":
+		b.handleDigest(ctx, msg)
+	case "notify":
+		b.handleNotify(msg)
+	case "schedule":
+		b.handleSchedule(ctx, msg)
+	case "settings":
+		b.handleSettings(ctx, msg)
+	case "add":
+		b.handleAdd(ctx, msg)
 	case "remove":
 		b.handleRemove(ctx, msg)
+	case "allow":
+		b.handleAllow(msg)
+	case "deny":
+		b.handleDeny(msg)
+	case "invite":
+		b.handleInvite(msg)
 	default:
 		b.reply(msg, "Unknown command. Use /help to see available commands.")
 	}
@@ -33,7 +83,7 @@ func (b *Bot) handleStart(msg *tgbotapi.Message) {
 	text := fmt.Sprintf(
 		"Welcome, %s!\n\n"+
 			"I can help you manage your Transmission downloads.\n\n"+
-			"Send me a .torrent file or a magnet link to add a new torrent.\n\n"+
+			"Send me a .torrent file, a magnet link or a link to a .torrent file to add a new torrent.\n\n"+
 			"Use /help to see all available commands.",
 		msg.From.FirstName,
 	)
@@ -47,50 +97,124 @@ func (b *Bot) handleHelp(msg *tgbotapi.Message) {
 /start - Start the bot
 /help - Show this help message
 /list - List all torrents
+/list owners - List all torrents with who added them
+/mine - List torrents you added
+/stats - Show transfer statistics
+/add <link> - Add torrents from magnet links, .torrent URLs or info-hashes
 /remove <id> - Remove torrent by ID
 /remove <id> data - Remove torrent and delete data
+/use [name] - Show or select the Transmission instance
+/dashboard - Pin a live dashboard in this chat
+/dashboard off - Remove the dashboard
+/digest - Show or set your daily or weekly digest
+/notify - Choose which notifications you get
+/schedule - Show the alt-speed schedule (admins can change it)
+/settings - Show Transmission's settings (admins can change some)
+
+With several instances, IDs look like nas:12, and
+/list nas, /mine nas, /stats nas, /schedule nas and
+/settings nas show a single instance.
+
+Admin commands:
+/allow <user_id> [role] - Grant access (admin, user, viewer)
+/deny <user_id> - Revoke access granted at runtime
+/invite [role] - Create a single-use invite link
+/settings log - List the latest setting changes
 
 You can also:
 • Send a .torrent file
-• Send a magnet link`
+• Send a magnet link
+• Send a link to a .torrent file
+• Send an info-hash
+• Send a .txt list of links, a .zip or an album of .torrent files
+
+Captions on files may hold options: paused, dir=/path`
 
 	b.reply(msg, text)
 }
 
 func (b *Bot) handleList(ctx context.Context, msg *tgbotapi.Message) {
-	torrents, err := b.trClient.ListTorrents(ctx)
-	if err != nil {
-		b.logger.Error("failed to list torrents", "error", err)
-		b.reply(msg, fmt.Sprintf("Failed to list torrents: %v", err))
+	torrents, failures := b.collectTorrents(ctx, b.listInstances(msg))
+	showOwners := slices.Contains(strings.Fields(msg.CommandArguments()), "owners")
 
-		return
+	b.replyTorrentList(msg, "Torrents", torrents, failures, showOwners)
+}
+
+func (b *Bot) handleMine(ctx context.Context, msg *tgbotapi.Message) {
+	torrents, failures := b.collectTorrents(ctx, b.listInstances(msg))
+
+	mine := make([]transmission.Torrent, 0, len(torrents))
+
+	for i := range torrents {
+		if isOwnedBy(&torrents[i], msg.From.ID) {
+			mine = append(mine, torrents[i])
+		}
+	}
+
+	b.replyTorrentList(msg, "Your torrents", mine, failures, false)
+}
+
+// replyTorrentList sends torrents in as many messages as needed, followed by
+// the instances that could not be listed.
+func (b *Bot) replyTorrentList(
+	msg *tgbotapi.Message,
+	title string,
+	torrents []transmission.Torrent,
+	failures []string,
+	showOwners bool,
+) {
+	if len(failures) > 0 {
+		defer b.reply(msg, strings.Join(failures, "\n"))
 	}
 
 	if len(torrents) == 0 {
-		b.reply(msg, "No torrents found")
+		if len(failures) == 0 {
+			b.reply(msg, "No torrents found")
+		}
 
 		return
 	}
 
-	header := fmt.Sprintf("Torrents (%d):\n", len(torrents))
+	lines := make([]string, 0, len(torrents))
+
+	for i := range torrents {
+		torrent := &torrents[i]
 
+		line := fmt.Sprintf(
+			"[%s] %s - %.0f%%",
+			b.torrentRef(torrent),
+			torrent.Name,
+			torrent.PercentDone*percentMultiply,
+		)
+
+		if owner := b.ownerName(torrent); showOwners && owner != "" {
+			line += " (" + owner + ")"
+		}
+
+		lines = append(lines, line)
+	}
+
+	for _, message := range splitMessage(fmt.Sprintf("%s (%d):", title, len(torrents)), lines) {
+		b.reply(msg, message)
+	}
+}
+
+// splitMessage joins a header and lines into as few messages as fit
+// Telegram's length limit, never splitting a line.
+func splitMessage(header string, lines []string) []string {
 	var messages []string
 
 	var current strings.Builder
 
 	current.WriteString(header)
 
-	for _, torrent := range torrents {
-		line := fmt.Sprintf(
-			"[%d] %s - %.0f%%\n",
-			torrent.ID,
-			torrent.Name,
-			torrent.PercentDone*percentMultiply,
-		)
+	for _, line := range lines {
+		line = "\n" + line
 
 		if current.Len()+len(line) > maxMessageLength {
 			messages = append(messages, current.String())
 			current.Reset()
+			line = strings.TrimPrefix(line, "\n")
 		}
 
 		current.WriteString(line)
@@ -100,9 +224,7 @@ func (b *Bot) handleList(ctx context.Context, msg *tgbotapi.Message) {
 		messages = append(messages, current.String())
 	}
 
-	for _, message := range messages {
-		b.reply(msg, message)
-	}
+	return messages
 }
 
 func (b *Bot) handleRemove(ctx context.Context, msg *tgbotapi.Message) {
@@ -114,33 +236,47 @@ func (b *Bot) handleRemove(ctx context.Context, msg *tgbotapi.Message) {
 		return
 	}
 
-	torrentID, err := strconv.ParseInt(args[0], 10, 64)
+	client, torrentID, err := b.parseTorrentRef(msg, args[0])
 	if err != nil {
-		b.reply(msg, "Invalid torrent ID. Please provide a numeric ID.")
+		b.reply(msg, torrentRefError(err))
 
 		return
 	}
 
-	torrent, getErr := b.trClient.GetTorrent(ctx, torrentID)
+	torrent, getErr := client.GetTorrent(ctx, torrentID)
 	if getErr != nil {
-		b.logger.Error("failed to get torrent", "error", getErr, "id", torrentID)
-		b.reply(msg, fmt.Sprintf("Failed to find torrent: %v", getErr))
+		b.logger.Error("failed to get torrent", "error", getErr, "id", torrentID, "instance", client.Name())
+		b.reply(msg, userError("Failed to find torrent", getErr))
+
+		return
+	}
+
+	if !b.canManage(msg.From.ID, torrent) {
+		b.reply(msg, "You can only remove torrents you added.")
 
 		return
 	}
 
 	deleteData := len(args) > 1 && args[1] == "data"
 
-	removeErr := b.trClient.RemoveTorrent(ctx, torrentID, deleteData)
+	removeErr := client.RemoveTorrent(ctx, torrentID, deleteData)
 	if removeErr != nil {
-		b.logger.Error("failed to remove torrent", "error", removeErr, "id", torrentID)
-		b.reply(msg, fmt.Sprintf("Failed to remove torrent: %v", removeErr))
+		b.logger.Error("failed to remove torrent", "error", removeErr, "id", torrentID, "instance", client.Name())
+		b.reply(msg, userError("Failed to remove torrent", removeErr))
 
 		return
 	}
 
+	b.metrics.TorrentRemoved()
+
+	forgetErr := b.store.ForgetOwnership(client.Name(), torrent.Hash)
+	if forgetErr != nil {
+		b.logger.Error("failed to forget torrent ownership", "error", forgetErr, "hash", torrent.Hash)
+	}
+
 	b.logger.Info("torrent removed",
 		"id", torrentID,
+		"instance", client.Name(),
 		"name", torrent.Name,
 		"delete_data", deleteData,
 		"user_id", msg.From.ID,
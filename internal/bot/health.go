@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lexfrei/transmission-bot/internal/health"
+)
+
+const (
+	healthReadHeaderTimeout = 5 * time.Second
+	healthShutdownTimeout   = 5 * time.Second
+)
+
+// serveHealth runs the health and metrics server until ctx is cancelled.
+// Failures are logged rather than returned: probes are optional and must not stop the bot.
+func (b *Bot) serveHealth(ctx context.Context) {
+	checks := map[string]health.Check{
+		"telegram": func(context.Context) error {
+			_, err := b.api.GetMe()
+			if err != nil {
+				return fmt.Errorf("getting bot info: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	for _, client := range b.instances {
+		name := "transmission"
+		if b.multiInstance() {
+			name += "/" + client.Name()
+		}
+
+		checks[name] = client.Ping
+	}
+
+	server := &http.Server{
+		Addr:              b.healthListen,
+		Handler:           health.NewHandler(checks, b.metrics.Registry),
+		ReadHeaderTimeout: healthReadHeaderTimeout,
+	}
+
+	// Shutdown waits for requests in progress, which may be calling Transmission.
+	shutdownDone := make(chan struct{})
+
+	go func() {
+		defer close(shutdownDone)
+
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), healthShutdownTimeout)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	b.logger.Info("health server started", "listen", b.healthListen)
+
+	err := server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		b.logger.Error("health server failed", "error", err)
+
+		return
+	}
+
+	<-shutdownDone
+}
+
+// countTorrents returns the number of torrents per status across all instances for the metrics gauge.
+func (b *Bot) countTorrents(ctx context.Context) (map[string]int, error) {
+	counts := make(map[string]int)
+
+	for _, client := range b.instances {
+		torrents, err := client.ListTorrents(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing torrents on %s: %w", client.Name(), err)
+		}
+
+		for i := range torrents {
+			counts[torrents[i].Status]++
+		}
+	}
+
+	return counts, nil
+}
+
+// updateType classifies an update for the updates metric.
+func updateType(update incomingUpdate) string {
+	switch {
+	case update.CallbackQuery != nil:
+		return "callback_query"
+	case update.Message == nil:
+		return "other"
+	case update.Message.IsCommand():
+		return "command"
+	case update.Message.Document != nil:
+		return "document"
+	default:
+		return "text"
+	}
+}
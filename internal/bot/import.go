@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+)
+
+var (
+	errImportTooMany  = errors.New("too many entries")
+	errImportTooLarge = errors.New("archive too large")
+)
+
+// importer reads the torrents of a batch import: links in a .txt file or
+// .torrent files in a .zip archive.
+type importer struct {
+	maxEntries int
+	maxSize    int64
+	timeout    time.Duration
+}
+
+func newImporter(cfg config.ImportConfig) *importer {
+	return &importer{maxEntries: cfg.MaxEntries, maxSize: cfg.MaxSize, timeout: cfg.Timeout}
+}
+
+// handleImport adds every torrent listed in a .txt file or packed in a .zip archive.
+func (b *Bot) handleImport(ctx context.Context, msg *tgbotapi.Message, extension string) {
+	opts, ok := b.documentOptions(msg, msg.Caption)
+	if !ok {
+		return
+	}
+
+	data, err := b.downloadDocument(ctx, msg.Document)
+	if err != nil {
+		b.logger.Error("failed to download file", "error", err)
+		b.reply(msg, b.downloadError(err))
+
+		return
+	}
+
+	var sources []torrentSource
+
+	if extension == ".zip" {
+		sources, err = b.importer.readArchive(data)
+	} else {
+		sources, err = b.importer.readList(b.listLinks(string(data)))
+	}
+
+	if err != nil {
+		b.logger.Warn("rejected batch import", "error", err, "file", msg.Document.FileName)
+		b.reply(msg, b.importer.importError(err))
+
+		return
+	}
+
+	if len(sources) == 0 {
+		b.reply(msg, "No torrents found in "+msg.Document.FileName)
+
+		return
+	}
+
+	importCtx, cancel := context.WithTimeout(ctx, b.importer.timeout)
+	defer cancel()
+
+	b.addSources(importCtx, msg, sources, opts)
+}
+
+// listLinks finds the links of a .txt file line by line, so that a line
+// holding only an info-hash counts as one, as a message would. Other URLs
+// than .torrent links are ignored, as in messages.
+func (b *Bot) listLinks(text string) []string {
+	var links []string
+
+	for line := range strings.Lines(text) {
+		links = append(links, b.findLinks(line, false)...)
+	}
+
+	return links
+}
+
+// readList checks the links of a .txt file against the entry limit.
+func (i *importer) readList(links []string) ([]torrentSource, error) {
+	if len(links) > i.maxEntries {
+		return nil, fmt.Errorf("%w: %d links", errImportTooMany, len(links))
+	}
+
+	return parseLinks(links), nil
+}
+
+// readArchive extracts the .torrent files of a .zip archive. Other files are
+// ignored; the limits apply to the .torrent files only, and the size limit
+// is enforced on the bytes actually read, not on what the archive claims.
+func (i *importer) readArchive(data []byte) ([]torrentSource, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("opening archive: %w", err)
+	}
+
+	var entries []*zip.File
+
+	for _, entry := range archive.File {
+		if !entry.FileInfo().IsDir() && strings.HasSuffix(strings.ToLower(entry.Name), ".torrent") {
+			entries = append(entries, entry)
+		}
+	}
+
+	if len(entries) > i.maxEntries {
+		return nil, fmt.Errorf("%w: %d .torrent files", errImportTooMany, len(entries))
+	}
+
+	sources := make([]torrentSource, 0, len(entries))
+	remaining := i.maxSize
+
+	for _, entry := range entries {
+		content, readErr := readEntry(entry, remaining)
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		remaining -= int64(len(content))
+
+		sources = append(sources, fileSource(path.Base(entry.Name), content))
+	}
+
+	return sources, nil
+}
+
+// fileSource wraps a .torrent file, keyed by its checksum so that the same
+// file sent twice is only added once.
+func fileSource(name string, data []byte) torrentSource {
+	checksum := sha256.Sum256(data)
+
+	return torrentSource{key: hex.EncodeToString(checksum[:]), file: name, data: data}
+}
+
+// readEntry decompresses an archive entry, failing once more than limit bytes come out.
+func readEntry(entry *zip.File, limit int64) ([]byte, error) {
+	reader, err := entry.Open()
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", entry.Name, err)
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	content, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", entry.Name, err)
+	}
+
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("%w: at %s", errImportTooLarge, entry.Name)
+	}
+
+	return content, nil
+}
+
+// importError explains to the user why a batch import was rejected.
+func (i *importer) importError(err error) string {
+	switch {
+	case errors.Is(err, errImportTooMany):
+		return fmt.Sprintf("Too many torrents in one file: at most %d are allowed.", i.maxEntries)
+	case errors.Is(err, errImportTooLarge):
+		return fmt.Sprintf("The archive is too large: at most %s may be extracted.", formatBytes(i.maxSize))
+	default:
+		return "Failed to read the archive. Please send a valid .zip file."
+	}
+}
@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// Callback actions for torrent buttons; the data is "action:instance:id".
+const (
+	callbackInfo   = "info"
+	callbackResume = "resume"
+)
+
+// callbackRef always namespaces the torrent, so a button keeps pointing at
+// the same instance whatever the user selects with /use later.
+func callbackRef(torrent *transmission.Torrent) string {
+	return torrent.Instance + torrentRefSeparator + strconv.FormatInt(torrent.ID, 10)
+}
+
+// torrentStatus summarizes progress and state, as in "73% done, seeding".
+func torrentStatus(torrent *transmission.Torrent) string {
+	return fmt.Sprintf("%.0f%% done, %s", torrent.PercentDone*percentMultiply, strings.ToLower(torrent.Status))
+}
+
+// torrentButtons returns an Info button and, for paused torrents, a Resume
+// button. suffix tells rows apart when several torrents share a keyboard.
+func torrentButtons(torrent *transmission.Torrent, suffix string) []tgbotapi.InlineKeyboardButton {
+	ref := callbackRef(torrent)
+
+	buttons := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("Info"+suffix, callbackInfo+":"+ref),
+	}
+
+	if torrent.Paused() {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("Resume"+suffix, callbackResume+":"+ref))
+	}
+
+	return buttons
+}
+
+// existingTorrent fetches the current state of a torrent reported as a
+// duplicate, falling back to what the add call returned.
+func (b *Bot) existingTorrent(ctx context.Context, client *transmission.Client, torrent *transmission.Torrent) *transmission.Torrent {
+	current, err := client.GetTorrent(ctx, torrent.ID)
+	if err != nil {
+		b.logger.Warn("failed to get existing torrent", "error", err, "id", torrent.ID, "instance", client.Name())
+
+		return torrent
+	}
+
+	return current
+}
+
+// callbackTorrent resolves the torrent a button refers to, answering the
+// callback with an error message when that fails.
+func (b *Bot) callbackTorrent(
+	ctx context.Context,
+	query *tgbotapi.CallbackQuery,
+	ref string,
+) (*transmission.Client, *transmission.Torrent, bool) {
+	client, torrentID, err := b.resolveTorrentRef(b.instances[0], ref)
+	if err != nil {
+		b.logger.Warn("invalid torrent reference in callback", "error", err, "data", query.Data)
+		b.answerCallback(query, msgTorrentNotFound)
+
+		return nil, nil, false
+	}
+
+	torrent, err := client.GetTorrent(ctx, torrentID)
+	if err != nil {
+		b.logger.Error("failed to get torrent", "error", err, "id", torrentID, "instance", client.Name())
+		b.answerCallback(query, userError("Failed to find torrent", err))
+
+		return nil, nil, false
+	}
+
+	return client, torrent, true
+}
+
+// handleInfoCallback replies with the details of a torrent.
+func (b *Bot) handleInfoCallback(ctx context.Context, query *tgbotapi.CallbackQuery, ref string) {
+	_, torrent, ok := b.callbackTorrent(ctx, query, ref)
+	if !ok {
+		return
+	}
+
+	b.answerCallback(query, "")
+
+	text := fmt.Sprintf("%s\nID: %s\nStatus: %s\nSize: %s",
+		torrent.Name, b.torrentRef(torrent), torrentStatus(torrent), formatBytes(torrent.TotalSize))
+
+	if owner := b.ownerName(torrent); owner != "" {
+		text += "\nAdded by: " + owner
+	}
+
+	if query.Message == nil {
+		return
+	}
+
+	b.replyWithMarkup(query.Message, text, nil)
+}
+
+// handleResumeCallback starts a paused torrent the user may manage.
+func (b *Bot) handleResumeCallback(ctx context.Context, query *tgbotapi.CallbackQuery, ref string) {
+	client, torrent, ok := b.callbackTorrent(ctx, query, ref)
+	if !ok {
+		return
+	}
+
+	if !b.canManage(query.From.ID, torrent) {
+		b.answerCallback(query, "You can only resume torrents you added.")
+
+		return
+	}
+
+	if !torrent.Paused() {
+		b.answerCallback(query, "Already running.")
+
+		return
+	}
+
+	startErr := client.StartTorrent(ctx, torrent.ID)
+	if startErr != nil {
+		b.logger.Error("failed to resume torrent", "error", startErr, "id", torrent.ID, "instance", client.Name())
+		b.answerCallback(query, userError("Failed to resume torrent", startErr))
+
+		return
+	}
+
+	b.logger.Info("torrent resumed", "id", torrent.ID, "instance", client.Name(), "user_id", query.From.ID)
+	b.answerCallback(query, "Resumed: "+torrent.Name)
+}
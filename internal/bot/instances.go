@@ -0,0 +1,210 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/store"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// torrentRefSeparator separates the instance name from the torrent ID, as in nas:12.
+const torrentRefSeparator = ":"
+
+var (
+	errUnknownInstance  = errors.New("unknown instance")
+	errInvalidTorrentID = errors.New("invalid torrent ID")
+)
+
+// instance returns the client of the named Transmission instance.
+func (b *Bot) instance(name string) (*transmission.Client, bool) {
+	for _, client := range b.instances {
+		if client.Name() == name {
+			return client, true
+		}
+	}
+
+	return nil, false
+}
+
+// multiInstance reports whether more than one Transmission instance is
+// configured, in which case torrent IDs carry the instance name.
+func (b *Bot) multiInstance() bool {
+	return len(b.instances) > 1
+}
+
+// currentInstance returns the instance selected with /use, falling back to the first configured one.
+func (b *Bot) currentInstance(userID int64) *transmission.Client {
+	if client, ok := b.instance(b.store.Preferences(userID).Instance); ok {
+		return client
+	}
+
+	return b.instances[0]
+}
+
+// commandInstance returns the instance named by a command argument, as in /list nas.
+func (b *Bot) commandInstance(msg *tgbotapi.Message) (*transmission.Client, bool) {
+	if !msg.IsCommand() {
+		return nil, false
+	}
+
+	for _, arg := range strings.Fields(msg.CommandArguments()) {
+		if client, ok := b.instance(arg); ok {
+			return client, true
+		}
+	}
+
+	return nil, false
+}
+
+// targetInstance returns the instance a command shows: the one named by an
+// argument if any, else the user's current instance.
+func (b *Bot) targetInstance(msg *tgbotapi.Message) *transmission.Client {
+	if client, ok := b.commandInstance(msg); ok {
+		return client
+	}
+
+	return b.currentInstance(msg.From.ID)
+}
+
+// torrentRef formats a torrent ID for display, namespaced when several instances are configured.
+func (b *Bot) torrentRef(torrent *transmission.Torrent) string {
+	if !b.multiInstance() {
+		return strconv.FormatInt(torrent.ID, 10)
+	}
+
+	return torrent.Instance + torrentRefSeparator + strconv.FormatInt(torrent.ID, 10)
+}
+
+// parseTorrentRef resolves a torrent reference such as 12 or nas:12. A bare ID
+// refers to the user's current instance.
+func (b *Bot) parseTorrentRef(msg *tgbotapi.Message, ref string) (*transmission.Client, int64, error) {
+	return b.resolveTorrentRef(b.currentInstance(msg.From.ID), ref)
+}
+
+// resolveTorrentRef resolves a torrent reference, using fallback for bare IDs.
+func (b *Bot) resolveTorrentRef(fallback *transmission.Client, ref string) (*transmission.Client, int64, error) {
+	client := fallback
+
+	name, rawID, namespaced := strings.Cut(ref, torrentRefSeparator)
+	if namespaced {
+		var ok bool
+
+		client, ok = b.instance(name)
+		if !ok {
+			return nil, 0, fmt.Errorf("%w: %q", errUnknownInstance, name)
+		}
+	} else {
+		rawID = name
+	}
+
+	torrentID, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %q", errInvalidTorrentID, rawID)
+	}
+
+	return client, torrentID, nil
+}
+
+// torrentRefError explains to the user why a torrent reference was rejected.
+func torrentRefError(err error) string {
+	if errors.Is(err, errUnknownInstance) {
+		return "Unknown instance. Use /use to see the available ones."
+	}
+
+	return "Invalid torrent ID. Please provide a numeric ID, like 12 or nas:12."
+}
+
+// listInstances returns the instances a listing command covers: the one named
+// by an argument, or all of them.
+func (b *Bot) listInstances(msg *tgbotapi.Message) []*transmission.Client {
+	if client, ok := b.commandInstance(msg); ok {
+		return []*transmission.Client{client}
+	}
+
+	return b.instances
+}
+
+// collectTorrents lists torrents on every given instance. Instances that fail
+// are reported as messages for the user instead of failing the whole listing.
+func (b *Bot) collectTorrents(ctx context.Context, clients []*transmission.Client) ([]transmission.Torrent, []string) {
+	var (
+		torrents []transmission.Torrent
+		failures []string
+	)
+
+	for _, client := range clients {
+		listed, err := client.ListTorrents(ctx)
+		if err != nil {
+			b.logger.Error("failed to list torrents", "error", err, "instance", client.Name())
+			failures = append(failures, b.instanceFailure(client, "Failed to list torrents", err))
+
+			continue
+		}
+
+		torrents = append(torrents, listed...)
+	}
+
+	return torrents, failures
+}
+
+// instanceFailure formats a user-facing error, prefixed with the instance name when several are configured.
+func (b *Bot) instanceFailure(client *transmission.Client, action string, err error) string {
+	text := userError(action, err)
+	if b.multiInstance() {
+		text = client.Name() + ": " + text
+	}
+
+	return text
+}
+
+// handleUse shows the instances or selects the one new torrents and bare IDs refer to.
+func (b *Bot) handleUse(msg *tgbotapi.Message) {
+	name := strings.TrimSpace(msg.CommandArguments())
+
+	if name == "" {
+		current := b.currentInstance(msg.From.ID)
+
+		var text strings.Builder
+
+		text.WriteString("Transmission instances:\n")
+
+		for _, client := range b.instances {
+			marker := "  "
+			if client == current {
+				marker = "• "
+			}
+
+			fmt.Fprintf(&text, "%s%s (%s)\n", marker, client.Name(), client.State())
+		}
+
+		text.WriteString("\nUsage: /use <name>")
+
+		b.reply(msg, text.String())
+
+		return
+	}
+
+	if _, ok := b.instance(name); !ok {
+		b.reply(msg, fmt.Sprintf("Unknown instance %q. Use /use to see the available ones.", name))
+
+		return
+	}
+
+	updateErr := b.store.UpdatePreferences(msg.From.ID, func(prefs *store.Preferences) {
+		prefs.Instance = name
+	})
+	if updateErr != nil {
+		b.logger.Error("failed to save instance selection", "error", updateErr, "user_id", msg.From.ID)
+		b.reply(msg, "Failed to save your selection. Please try again.")
+
+		return
+	}
+
+	b.reply(msg, fmt.Sprintf("Now using %s. New torrents and bare IDs refer to it.", name))
+}
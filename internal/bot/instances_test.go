@@ -0,0 +1,67 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+func TestCommandAddressing(t *testing.T) {
+	t.Parallel()
+
+	bot := &Bot{api: &tgbotapi.BotAPI{Self: tgbotapi.User{UserName: "TorrentBot"}}}
+
+	for _, name := range []string{"nas", "seedbox"} {
+		client, err := transmission.NewClient(config.TransmissionConfig{},
+			config.InstanceConfig{Name: name, URL: "http://" + name + ".local:9091/transmission/rpc"})
+		if err != nil {
+			t.Fatalf("NewClient(%s) error = %v", name, err)
+		}
+
+		bot.instances = append(bot.instances, client)
+	}
+
+	tests := []struct {
+		text         string
+		wantForMe    bool
+		wantInstance string
+	}{
+		{text: "/list", wantForMe: true},
+		{text: "/list@torrentbot", wantForMe: true},
+		{text: "/list@otherbot"},
+		{text: "/list@nas"},
+		{text: "/stats nas", wantForMe: true, wantInstance: "nas"},
+		{text: "/list@TorrentBot owners seedbox", wantForMe: true, wantInstance: "seedbox"},
+		{text: "/settings log", wantForMe: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			t.Parallel()
+
+			command, _, _ := strings.Cut(test.text, " ")
+			msg := &tgbotapi.Message{Text: test.text, Entities: []tgbotapi.MessageEntity{
+				{Type: "bot_command", Offset: 0, Length: len(command)},
+			}}
+
+			if got := bot.commandForMe(msg); got != test.wantForMe {
+				t.Errorf("commandForMe() = %v, want %v", got, test.wantForMe)
+			}
+
+			client, ok := bot.commandInstance(msg)
+
+			got := ""
+			if ok {
+				got = client.Name()
+			}
+
+			if got != test.wantInstance {
+				t.Errorf("commandInstance() = %q, want %q", got, test.wantInstance)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package bot
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/bencode"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// linkRegex finds magnet links and http(s) URLs in a message, in the order they appear.
+var linkRegex = regexp.MustCompile(`magnet:\?\S+|https?://\S+`)
+
+// bareHashRegex matches a bare info-hash: 40 hex or 32 base32 characters.
+var bareHashRegex = regexp.MustCompile(`^(?:[0-9a-fA-F]{40}|[A-Za-z2-7]{32})$`)
+
+const magnetPrefix = "magnet:"
+
+// torrentSource is one torrent to add: a link found in a message or a
+// .torrent file taken from an archive.
+type torrentSource struct {
+	// url is the link as sent, or the magnet link made from a bare info-hash.
+	url string
+	// key identifies the torrent, or the file for URLs, to skip repeated ones.
+	key string
+	// name is the display name (dn) of a magnet link, if any.
+	name   string
+	magnet bool
+	// file and data hold a .torrent file taken from an archive.
+	file string
+	data []byte
+	// failure is reported instead of adding a source rejected up front.
+	failure string
+}
+
+// findLinks returns the magnet links and .torrent URLs in text, and bare
+// info-hashes turned into magnet links. A bare hash only counts when it is the
+// whole text, since any 32-letter word would otherwise be taken for one. With
+// explicit set, as for the arguments of /add, every word that is a hash counts
+// and other URLs are kept too.
+func (b *Bot) findLinks(text string, explicit bool) []string {
+	if hash := strings.TrimSpace(text); bareHashRegex.MatchString(hash) {
+		return []string{hashMagnet(hash, b.trackers)}
+	}
+
+	var links []string
+
+	for _, word := range strings.Fields(text) {
+		if explicit && bareHashRegex.MatchString(word) {
+			links = append(links, hashMagnet(word, b.trackers))
+
+			continue
+		}
+
+		for _, link := range linkRegex.FindAllString(word, -1) {
+			if strings.HasPrefix(link, magnetPrefix) || explicit || isTorrentURL(link) {
+				links = append(links, link)
+			}
+		}
+	}
+
+	return links
+}
+
+// parseLinks validates links found by findLinks. Invalid magnet links are
+// kept with their failure, to be reported along with the rest.
+func parseLinks(links []string) []torrentSource {
+	sources := make([]torrentSource, 0, len(links))
+
+	for _, link := range links {
+		if !strings.HasPrefix(link, magnetPrefix) {
+			sources = append(sources, torrentSource{url: link, key: link})
+
+			continue
+		}
+
+		magnet, err := parseMagnet(link)
+		if err != nil {
+			sources = append(sources, torrentSource{failure: "Invalid magnet link: " + err.Error()})
+
+			continue
+		}
+
+		sources = append(sources, torrentSource{url: link, key: magnet.hash, name: magnet.name, magnet: true})
+	}
+
+	return sources
+}
+
+// isTorrentURL reports whether the path of a URL ends in .torrent.
+func isTorrentURL(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasSuffix(strings.ToLower(parsed.Path), ".torrent")
+}
+
+// handleAdd adds the magnet links and .torrent URLs given as arguments.
+func (b *Bot) handleAdd(ctx context.Context, msg *tgbotapi.Message) {
+	links := b.findLinks(msg.CommandArguments(), true)
+	if len(links) == 0 {
+		b.reply(msg, "Usage: /add <magnet link, .torrent URL or info-hash> [...]")
+
+		return
+	}
+
+	b.addSources(ctx, msg, parseLinks(links), addOptionsFor(msg.From))
+}
+
+// addSources adds every torrent and replies with one summary line per
+// source. A single new torrent gets the same reply as a sent file instead.
+func (b *Bot) addSources(
+	ctx context.Context,
+	msg *tgbotapi.Message,
+	sources []torrentSource,
+	opts transmission.AddOptions,
+) {
+	results := make([]string, 0, len(sources))
+	client := b.currentInstance(msg.From.ID)
+	seen := make(map[string]struct{}, len(sources))
+
+	var (
+		added      []transmission.Torrent
+		duplicates []*transmission.Torrent
+	)
+
+	for _, source := range sources {
+		if skipped := skipReason(ctx, source, seen); skipped != "" {
+			results = append(results, skipped)
+
+			continue
+		}
+
+		result, failure := b.addSource(ctx, client, source, opts)
+
+		switch {
+		case result == nil:
+			results = append(results, failure)
+		case result.Duplicate:
+			existing := b.existingTorrent(ctx, client, &result.Torrent)
+			duplicates = append(duplicates, existing)
+			results = append(results, fmt.Sprintf("Already exists: ID %s, %s - %s",
+				b.torrentRef(existing), torrentStatus(existing), existing.Name))
+		default:
+			b.torrentAdded(&result.Torrent, msg.From)
+
+			// Until Transmission has the metadata, a magnet's torrent is named after its hash.
+			if source.name != "" {
+				result.Torrent.Name = source.name
+			}
+
+			added = append(added, result.Torrent)
+			results = append(results, fmt.Sprintf("ID: %s - %s", b.torrentRef(&result.Torrent), result.Torrent.Name))
+		}
+	}
+
+	if len(sources) == 1 && len(added) == 1 {
+		b.replyAdded(ctx, msg, client, &added[0])
+
+		return
+	}
+
+	b.replySummary(msg, fmt.Sprintf("Added %d of %d torrent(s):", len(added), len(sources)), results, duplicates)
+}
+
+// skipReason returns why a source is not added, or "" if it should be.
+// seen collects the keys of the sources already handled.
+func skipReason(ctx context.Context, source torrentSource, seen map[string]struct{}) string {
+	if source.failure != "" {
+		return source.failure
+	}
+
+	if ctx.Err() != nil {
+		return "Skipped: ran out of time before reaching this one"
+	}
+
+	if _, repeated := seen[source.key]; repeated {
+		return "Skipped: the same torrent appears earlier in this message"
+	}
+
+	seen[source.key] = struct{}{}
+
+	return ""
+}
+
+// replySummary sends the result lines of an add, split to fit Telegram's
+// limit; the buttons of already existing torrents go under the last part.
+func (b *Bot) replySummary(msg *tgbotapi.Message, header string, results []string, duplicates []*transmission.Torrent) {
+	messages := splitMessage(header, results)
+	for i, message := range messages {
+		var markup any
+		if i == len(messages)-1 {
+			markup = b.duplicateKeyboard(duplicates)
+		}
+
+		b.replyWithMarkup(msg, message, markup)
+	}
+}
+
+// addSource adds one torrent: a magnet link, a .torrent URL, which is
+// downloaded by the bot, or a file. On failure it returns the line to report instead.
+func (b *Bot) addSource(
+	ctx context.Context,
+	client *transmission.Client,
+	source torrentSource,
+	opts transmission.AddOptions,
+) (*transmission.AddResult, string) {
+	if source.magnet {
+		result, err := client.AddTorrentByMagnet(ctx, source.url, opts)
+		if err != nil {
+			b.logger.Error("failed to add magnet", "error", err, "instance", client.Name())
+
+			return nil, b.instanceFailure(client, "Failed to add magnet", err)
+		}
+
+		return result, ""
+	}
+
+	shown, data := source.file, source.data
+
+	if data == nil {
+		shown = displayURL(source.url)
+
+		var err error
+
+		data, err = b.fetcher.fetch(ctx, source.url)
+		if err != nil {
+			b.logger.Warn("failed to download torrent", "error", err, "url", shown)
+
+			return nil, fmt.Sprintf("Failed to download %s: %s", shown, b.fetcher.fetchError(err))
+		}
+	}
+
+	validateErr := bencode.ValidateTorrent(data)
+	if validateErr != nil {
+		b.logger.Info("rejected invalid torrent file", "error", validateErr, "source", shown)
+
+		return nil, shown + ": " + userError("Failed to read torrent file", validateErr)
+	}
+
+	result, err := client.AddTorrentByFile(ctx, base64.StdEncoding.EncodeToString(data), opts)
+	if err != nil {
+		b.logger.Error("failed to add torrent", "error", err, "instance", client.Name(), "source", shown)
+
+		return nil, shown + ": " + b.instanceFailure(client, "Failed to add torrent", err)
+	}
+
+	return result, ""
+}
@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Reasons a magnet link is rejected; their text is shown to the user.
+var (
+	errMagnetMalformed = errors.New("malformed link")
+	errMagnetNoHash    = errors.New("no valid btih or btmh info-hash")
+	errMagnetTracker   = errors.New("invalid tracker URL")
+)
+
+const (
+	btihPrefix = "urn:btih:"
+	btmhPrefix = "urn:btmh:"
+	// sha256Multihash prefixes a v2 info-hash: the SHA-256 code and a 32-byte length.
+	sha256Multihash = "1220"
+	hexHashLength   = 40
+	v2HashLength    = len(sha256Multihash) + 64
+)
+
+var (
+	hexHashRegex    = regexp.MustCompile(`^[0-9a-f]+$`)
+	base32HashRegex = regexp.MustCompile(`^[A-Z2-7]{32}$`)
+)
+
+// magnetLink is a validated magnet link.
+type magnetLink struct {
+	// hash is the v1 info-hash in lowercase hex, or the v2 multihash of
+	// v2-only links; it identifies the torrent whatever the encoding.
+	hash string
+	name string
+}
+
+// parseMagnet validates a magnet link: it needs a btih or btmh exact topic
+// (xt), and its trackers (tr) must be absolute URLs.
+func parseMagnet(raw string) (*magnetLink, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "magnet" || parsed.Opaque != "" {
+		return nil, errMagnetMalformed
+	}
+
+	query, err := url.ParseQuery(parsed.RawQuery)
+	if err != nil {
+		return nil, errMagnetMalformed
+	}
+
+	magnet := &magnetLink{name: query.Get("dn")}
+
+	for _, topic := range query["xt"] {
+		hash, ok := topicHash(topic)
+		if !ok {
+			continue
+		}
+
+		// Hybrid links carry both; the v1 hash is what Transmission reports.
+		if magnet.hash == "" || strings.HasPrefix(strings.ToLower(topic), btihPrefix) {
+			magnet.hash = hash
+		}
+	}
+
+	if magnet.hash == "" {
+		return nil, errMagnetNoHash
+	}
+
+	for _, tracker := range query["tr"] {
+		trackerURL, trackerErr := url.Parse(tracker)
+		if trackerErr != nil || trackerURL.Scheme == "" || trackerURL.Host == "" {
+			return nil, fmt.Errorf("%w: %q", errMagnetTracker, tracker)
+		}
+	}
+
+	return magnet, nil
+}
+
+// topicHash extracts the info-hash of a btih or btmh exact topic, normalized
+// to lowercase hex.
+func topicHash(topic string) (string, bool) {
+	lower := strings.ToLower(topic)
+
+	if hash, ok := strings.CutPrefix(lower, btmhPrefix); ok {
+		valid := len(hash) == v2HashLength && strings.HasPrefix(hash, sha256Multihash) && hexHashRegex.MatchString(hash)
+
+		return hash, valid
+	}
+
+	if _, ok := strings.CutPrefix(lower, btihPrefix); !ok {
+		return "", false
+	}
+
+	return infoHash(topic[len(btihPrefix):])
+}
+
+// infoHash normalizes a v1 info-hash given as 40 hex or 32 base32 characters to lowercase hex.
+func infoHash(hash string) (string, bool) {
+	if len(hash) == hexHashLength {
+		hash = strings.ToLower(hash)
+
+		return hash, hexHashRegex.MatchString(hash)
+	}
+
+	upper := strings.ToUpper(hash)
+	if !base32HashRegex.MatchString(upper) {
+		return "", false
+	}
+
+	decoded, err := base32.StdEncoding.DecodeString(upper)
+	if err != nil {
+		return "", false
+	}
+
+	return hex.EncodeToString(decoded), true
+}
+
+// hashMagnet turns a bare info-hash into a magnet link announcing to trackers.
+func hashMagnet(hash string, trackers []string) string {
+	var link strings.Builder
+
+	link.WriteString("magnet:?xt=" + btihPrefix + hash)
+
+	for _, tracker := range trackers {
+		link.WriteString("&tr=" + url.QueryEscape(tracker))
+	}
+
+	return link.String()
+}
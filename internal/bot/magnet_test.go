@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+const (
+	testHexHash    = "0123456789abcdef0123456789abcdef01234567"
+	testBase32Hash = "AERUKZ4JVPG66AJDIVTYTK6N54ASGRLH"
+	testV2Hash     = "1220" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+)
+
+func TestInfoHash(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		hash   string
+		want   string
+		wantOK bool
+	}{
+		{name: "hex", hash: testHexHash, want: testHexHash, wantOK: true},
+		{name: "upper hex", hash: "0123456789ABCDEF0123456789ABCDEF01234567", want: testHexHash, wantOK: true},
+		{name: "base32", hash: testBase32Hash, want: testHexHash, wantOK: true},
+		{name: "lower base32", hash: "aerukz4jvpg66ajdivtytk6n54asgrlh", want: testHexHash, wantOK: true},
+		{name: "hex with non-hex character", hash: "0123456789abcdef0123456789abcdef0123456g"},
+		{name: "base32 with invalid character", hash: "AERUKZ4JVPG66AJDIVTYTK6N54ASGRL1"},
+		{name: "too short", hash: "0123456789abcdef"},
+		{name: "empty"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := infoHash(test.hash)
+			if ok != test.wantOK || (ok && got != test.want) {
+				t.Errorf("infoHash(%q) = %q, %v; want %q, %v", test.hash, got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseMagnet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		link     string
+		wantHash string
+		wantName string
+		wantErr  error
+	}{
+		{name: "v1 hex", link: "magnet:?xt=urn:btih:" + testHexHash + "&dn=Ubuntu",
+			wantHash: testHexHash, wantName: "Ubuntu"},
+		{name: "v1 base32", link: "magnet:?xt=urn:btih:" + testBase32Hash, wantHash: testHexHash},
+		{name: "v2 only", link: "magnet:?xt=urn:btmh:" + testV2Hash, wantHash: testV2Hash},
+		{name: "hybrid prefers v1", link: "magnet:?xt=urn:btmh:" + testV2Hash + "&xt=urn:btih:" + testHexHash,
+			wantHash: testHexHash},
+		{name: "valid tracker", link: "magnet:?xt=urn:btih:" + testHexHash + "&tr=udp%3A%2F%2Ftracker.example.org%3A1337",
+			wantHash: testHexHash},
+		{name: "no hash", link: "magnet:?dn=Ubuntu", wantErr: errMagnetNoHash},
+		{name: "bad hash", link: "magnet:?xt=urn:btih:1234", wantErr: errMagnetNoHash},
+		{name: "v2 with wrong multihash", link: "magnet:?xt=urn:btmh:1114" + testV2Hash[4:], wantErr: errMagnetNoHash},
+		{name: "relative tracker", link: "magnet:?xt=urn:btih:" + testHexHash + "&tr=tracker", wantErr: errMagnetTracker},
+		{name: "not a magnet", link: "http://example.org/?xt=urn:btih:" + testHexHash, wantErr: errMagnetMalformed},
+		{name: "bad query", link: "magnet:?xt=%zz", wantErr: errMagnetMalformed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			magnet, err := parseMagnet(test.link)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("parseMagnet() error = %v, want %v", err, test.wantErr)
+			}
+
+			if err == nil && (magnet.hash != test.wantHash || magnet.name != test.wantName) {
+				t.Errorf("parseMagnet() = %+v, want hash %q and name %q", magnet, test.wantHash, test.wantName)
+			}
+		})
+	}
+}
+
+func TestFindLinks(t *testing.T) {
+	t.Parallel()
+
+	hashLink := "magnet:?xt=urn:btih:" + testHexHash
+	magnet := "magnet:?xt=urn:btih:" + testBase32Hash
+
+	tests := []struct {
+		name     string
+		text     string
+		explicit bool
+		want     []string
+	}{
+		{name: "bare hash message", text: " " + testHexHash + "\n", want: []string{hashLink}},
+		{name: "hash inside a sentence", text: "see " + testHexHash + " please"},
+		{name: "32-letter word", text: "the word abcdefghijklmnopqrstuvwxyzabcdef is not a hash"},
+		{name: "hash as add argument", text: testHexHash + " " + magnet, explicit: true,
+			want: []string{hashLink, magnet}},
+		{name: "magnet in text", text: "try " + magnet + " now", want: []string{magnet}},
+		{name: "torrent URL", text: "https://example.org/a.torrent https://example.org/page",
+			want: []string{"https://example.org/a.torrent"}},
+		{name: "explicit URL", text: "https://example.org/page", explicit: true,
+			want: []string{"https://example.org/page"}},
+	}
+
+	bot := &Bot{}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := bot.findLinks(test.text, test.explicit); !slices.Equal(got, test.want) {
+				t.Errorf("findLinks(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,517 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+	"github.com/lexfrei/transmission-bot/internal/store"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// Notification events a user can turn on and off with /notify.
+const (
+	eventCompletion = "completion"
+	eventErrors     = "errors"
+	eventStalls     = "stalls"
+	eventDisk       = "disk"
+	eventDigest     = "digest"
+)
+
+// The callback action of the /notify buttons, whose data is "notify:event",
+// and its arguments other than event names.
+const (
+	callbackNotify = "notify"
+	notifyScope    = "scope"
+	notifyHold     = "hold"
+)
+
+// maxHeld caps the notifications held back per user during quiet hours; the
+// oldest are dropped first.
+const maxHeld = 50
+
+const quietUsage = `Set quiet hours with:
+/notify quiet 22:00-07:00 [timezone]
+/notify quiet hold - deliver held notifications when they end
+/notify quiet silent - deliver notifications without sound
+/notify quiet off`
+
+var errQuietUsage = errors.New("invalid quiet hours")
+
+// notifyEvents is the order and labels of the events in /notify. Events marked
+// adminDefault are on by default for admins only; other users turn them on.
+//
+//nolint:gochecknoglobals // static lookup table
+var notifyEvents = []struct {
+	name         string
+	label        string
+	adminDefault bool
+}{
+	{eventCompletion, "Completed downloads", false},
+	{eventErrors, "Errors", false},
+	{eventStalls, "Stalled downloads", true},
+	{eventDisk, "Low disk space", true},
+	{eventDigest, "Digest", false},
+}
+
+// notification is an event to tell the users who want it about.
+type notification struct {
+	event string
+	// torrent is what the event is about; nil for disk alerts.
+	torrent *transmission.Torrent
+	text    string
+}
+
+// notifier watches Transmission for events and holds back notifications
+// during quiet hours.
+type notifier struct {
+	interval     time.Duration
+	stallAfter   time.Duration
+	minFreeSpace int64
+
+	// torrents and lowDisk are only used by the watcher goroutine.
+	torrents map[string]map[string]*torrentState
+	lowDisk  map[string]bool
+
+	mu sync.Mutex
+	// releasing are the users whose held notifications are being sent.
+	releasing map[int64]struct{}
+}
+
+func newNotifier(cfg config.NotifyConfig) *notifier {
+	return &notifier{
+		interval:     cfg.Interval,
+		stallAfter:   cfg.StallAfter,
+		minFreeSpace: cfg.MinFreeSpace,
+		torrents:     make(map[string]map[string]*torrentState),
+		lowDisk:      make(map[string]bool),
+		releasing:    make(map[int64]struct{}),
+	}
+}
+
+// beginRelease marks the held notifications of a user as being sent,
+// reporting false if they already are.
+func (n *notifier) beginRelease(userID int64) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.releasing[userID]; ok {
+		return false
+	}
+
+	n.releasing[userID] = struct{}{}
+
+	return true
+}
+
+func (n *notifier) finishRelease(userID int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.releasing, userID)
+}
+
+// handleNotify shows the notification settings of the user with buttons to
+// change them, or sets quiet hours with "/notify quiet ...".
+func (b *Bot) handleNotify(msg *tgbotapi.Message) {
+	args := strings.Fields(msg.CommandArguments())
+
+	if len(args) > 0 && strings.EqualFold(args[0], "quiet") {
+		b.handleQuietHours(msg, args[1:])
+
+		return
+	}
+
+	text, markup := renderNotify(b.store.Preferences(msg.From.ID).Notifications, b.can(msg.From.ID, permManageAll))
+	b.replyWithMarkup(msg, text, markup)
+}
+
+// handleQuietHours sets, changes or turns off the quiet hours of the user.
+func (b *Bot) handleQuietHours(msg *tgbotapi.Message, args []string) {
+	current := b.store.Preferences(msg.From.ID).Notifications.Quiet
+
+	quiet, err := parseQuietHours(args, current)
+	if err != nil {
+		b.reply(msg, quietUsage)
+
+		return
+	}
+
+	updateErr := b.store.UpdatePreferences(msg.From.ID, func(prefs *store.Preferences) {
+		prefs.Notifications.Quiet = quiet
+	})
+	if updateErr != nil {
+		b.logger.Error("failed to save quiet hours", "error", updateErr, "user_id", msg.From.ID)
+		b.reply(msg, "Failed to save your quiet hours. Please try again.")
+
+		return
+	}
+
+	b.reply(msg, "Quiet hours: "+describeQuietHours(quiet)+".")
+}
+
+// parseQuietHours reads "HH:MM-HH:MM [zone]", "hold", "silent" or "off".
+// Without a zone, current's zone is kept. Nil turns quiet hours off.
+func parseQuietHours(args []string, current *store.QuietHours) (*store.QuietHours, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, errQuietUsage
+	}
+
+	word := strings.ToLower(args[0])
+
+	switch {
+	case word == "off":
+		return nil, nil //nolint:nilnil // nil turns quiet hours off
+	case word == notifyHold || word == "silent":
+		if current == nil {
+			return nil, errQuietUsage
+		}
+
+		changed := *current
+		changed.Hold = word == notifyHold
+
+		return &changed, nil
+	}
+
+	startText, endText, ok := strings.Cut(args[0], "-")
+	if !ok {
+		return nil, errQuietUsage
+	}
+
+	start, startErr := config.ParseClock(startText)
+	end, endErr := config.ParseClock(endText)
+
+	if startErr != nil || endErr != nil || start == end {
+		return nil, errQuietUsage
+	}
+
+	quiet := &store.QuietHours{Start: start, End: end, Timezone: time.UTC.String()}
+
+	if current != nil {
+		quiet.Timezone, quiet.Hold = current.Timezone, current.Hold
+	}
+
+	if len(args) == 2 {
+		quiet.Timezone = args[1]
+	}
+
+	_, zoneErr := time.LoadLocation(quiet.Timezone)
+	if zoneErr != nil {
+		return nil, fmt.Errorf("%w: %w", errQuietUsage, zoneErr)
+	}
+
+	return quiet, nil
+}
+
+func describeQuietHours(quiet *store.QuietHours) string {
+	if quiet == nil {
+		return "off"
+	}
+
+	mode := "delivered silently"
+	if quiet.Hold {
+		mode = "held until they end"
+	}
+
+	return fmt.Sprintf("%s-%s (%s), notifications %s", formatClock(quiet.Start), formatClock(quiet.End), quiet.Timezone, mode)
+}
+
+// inQuietHours reports whether now falls within the quiet hours.
+func inQuietHours(quiet *store.QuietHours, now time.Time) bool {
+	if quiet == nil {
+		return false
+	}
+
+	location, err := time.LoadLocation(quiet.Timezone)
+	if err != nil {
+		return false
+	}
+
+	local := now.In(location)
+	minute := local.Hour()*config.MinutesPerHour + local.Minute()
+
+	if quiet.Start < quiet.End {
+		return minute >= quiet.Start && minute < quiet.End
+	}
+
+	return minute >= quiet.Start || minute < quiet.End
+}
+
+// renderNotify shows the notification settings of a user, an admin or not,
+// with a button for each.
+func renderNotify(settings store.Notifications, admin bool) (string, tgbotapi.InlineKeyboardMarkup) {
+	var text strings.Builder
+
+	text.WriteString("Notifications:\n")
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(notifyEvents)+2)
+
+	for _, event := range notifyEvents {
+		state := onOff(eventOn(settings, event.name, admin))
+
+		fmt.Fprintf(&text, "%s: %s\n", event.label, state)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(event.label+": "+state, callbackNotify+":"+event.name)))
+	}
+
+	scope := "your torrents"
+	if settings.AllTorrents {
+		scope = "all torrents"
+	}
+
+	fmt.Fprintf(&text, "Torrent events for: %s\n", scope)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("For: "+scope, callbackNotify+":"+notifyScope)))
+
+	fmt.Fprintf(&text, "Quiet hours: %s\n\n%s", describeQuietHours(settings.Quiet), quietUsage)
+
+	if settings.Quiet != nil {
+		label := "Quiet hours: deliver silently"
+		if settings.Quiet.Hold {
+			label = "Quiet hours: hold"
+		}
+
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, callbackNotify+":"+notifyHold)))
+	}
+
+	return text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// handleNotifyCallback toggles the setting of a /notify button for the user
+// who pressed it and shows their updated settings.
+func (b *Bot) handleNotifyCallback(query *tgbotapi.CallbackQuery, setting string) {
+	var settings store.Notifications
+
+	admin := b.can(query.From.ID, permManageAll)
+
+	updateErr := b.store.UpdatePreferences(query.From.ID, func(prefs *store.Preferences) {
+		toggleNotify(&prefs.Notifications, setting, admin)
+		settings = prefs.Notifications
+	})
+	if updateErr != nil {
+		b.logger.Error("failed to save notification settings", "error", updateErr, "user_id", query.From.ID)
+		b.answerCallback(query, "Failed to save your settings. Please try again.")
+
+		return
+	}
+
+	b.answerCallback(query, "")
+
+	if query.Message == nil {
+		return
+	}
+
+	text, markup := renderNotify(settings, admin)
+
+	editErr := b.edit(query.Message.Chat.ID, query.Message.MessageID, text, &markup)
+	if editErr != nil && !isNotModified(editErr) {
+		b.logger.Warn("failed to update notification settings", "error", editErr, "user_id", query.From.ID)
+	}
+}
+
+// toggleNotify flips a setting for a user, an admin or not. Settings are
+// copied, not changed in place, since earlier copies of the preferences share them.
+func toggleNotify(settings *store.Notifications, setting string, admin bool) {
+	switch setting {
+	case notifyScope:
+		settings.AllTorrents = !settings.AllTorrents
+	case notifyHold:
+		if settings.Quiet != nil {
+			quiet := *settings.Quiet
+			quiet.Hold = !quiet.Hold
+			settings.Quiet = &quiet
+		}
+	default:
+		if !knownEvent(setting) {
+			return
+		}
+
+		// Turning an event on unmutes it and, if it is still off by default,
+		// enables it; turning it off does the reverse.
+		if eventOn(*settings, setting, admin) {
+			settings.Enabled = without(settings.Enabled, setting)
+			if eventOn(*settings, setting, admin) {
+				settings.Muted = append(slices.Clip(settings.Muted), setting)
+			}
+		} else {
+			settings.Muted = without(settings.Muted, setting)
+			if !eventOn(*settings, setting, admin) {
+				settings.Enabled = append(slices.Clip(settings.Enabled), setting)
+			}
+		}
+	}
+}
+
+// without returns a copy of list without name.
+func without(list []string, name string) []string {
+	return slices.DeleteFunc(slices.Clone(list), func(item string) bool { return item == name })
+}
+
+func knownEvent(name string) bool {
+	for _, event := range notifyEvents {
+		if event.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// eventOn reports whether a user, an admin or not, gets an event: it is on
+// unless muted, except that events off by default must have been enabled.
+func eventOn(settings store.Notifications, name string, admin bool) bool {
+	if slices.Contains(settings.Muted, name) {
+		return false
+	}
+
+	for _, event := range notifyEvents {
+		if event.name == name && event.adminDefault && !admin {
+			return slices.Contains(settings.Enabled, name)
+		}
+	}
+
+	return true
+}
+
+// notify delivers a notification to every user who may see it, has a private
+// chat with the bot and wants it: the event is on and, for torrent events, the
+// torrent is theirs or their scope is all torrents.
+func (b *Bot) notify(note notification) {
+	for _, user := range b.store.Users() {
+		if !user.PrivateChat || !b.can(user.ID, permView) {
+			continue
+		}
+
+		settings := b.store.Preferences(user.ID).Notifications
+		if !eventOn(settings, note.event, b.can(user.ID, permManageAll)) {
+			continue
+		}
+
+		if note.torrent != nil && !settings.AllTorrents && !isOwnedBy(note.torrent, user.ID) {
+			continue
+		}
+
+		b.deliver(user.ID, settings.Quiet, note.text)
+	}
+}
+
+// deliver sends a notification in the user's private chat. During quiet
+// hours it is held back or sent without sound, as the user chose.
+func (b *Bot) deliver(userID int64, quiet *store.QuietHours, text string) {
+	message := tgbotapi.NewMessage(userID, text)
+
+	if inQuietHours(quiet, time.Now()) {
+		if quiet.Hold {
+			b.hold(userID, text)
+
+			return
+		}
+
+		message.DisableNotification = true
+	}
+
+	_, err := b.send(message, 0)
+	if err != nil {
+		b.logger.Warn("failed to send notification", "error", err, "user_id", userID)
+	}
+}
+
+// hold saves a notification in the store until the user's quiet hours end.
+func (b *Bot) hold(userID int64, text string) {
+	err := b.store.UpdatePreferences(userID, func(prefs *store.Preferences) {
+		held := append(prefs.Held, text)
+		prefs.Held = held[max(0, len(held)-maxHeld):]
+	})
+	if err != nil {
+		b.logger.Error("failed to hold notification", "error", err, "user_id", userID)
+	}
+}
+
+// releaseHeld is the scheduler job that delivers held notifications once the
+// user's quiet hours are over, or turned off.
+func (b *Bot) releaseHeld(ctx context.Context, minute time.Time) {
+	for userID, prefs := range b.store.AllPreferences() {
+		if len(prefs.Held) == 0 || inQuietHours(prefs.Notifications.Quiet, minute) {
+			continue
+		}
+
+		if !b.notifier.beginRelease(userID) {
+			continue
+		}
+
+		b.background.start(ctx, func(ctx context.Context) {
+			defer b.notifier.finishRelease(userID)
+
+			b.sendHeld(ctx, userID, prefs.Held)
+		})
+	}
+}
+
+// sendHeld delivers the notifications held during quiet hours, in as few
+// messages as fit, and forgets each message's notifications once it is sent.
+// What isn't sent stays held for the next minute or the next start, unless
+// the bot can no longer write to the chat.
+func (b *Bot) sendHeld(ctx context.Context, userID int64, held []string) {
+	for len(held) > 0 && ctx.Err() == nil {
+		text, count := heldMessage(held)
+
+		_, err := b.send(tgbotapi.NewMessage(userID, text), 0)
+		if err != nil {
+			b.logger.Warn("failed to send held notifications", "error", err, "user_id", userID)
+
+			// A chat the bot can't write to won't take them later either.
+			if isMessageGone(err) {
+				b.forgetHeld(userID, held)
+			}
+
+			return
+		}
+
+		b.forgetHeld(userID, held[:count])
+		held = held[count:]
+	}
+}
+
+// heldMessage returns a message with as many of the first held notifications
+// as fit, at least one, and how many it has.
+func heldMessage(held []string) (string, int) {
+	var text strings.Builder
+
+	text.WriteString("While your notifications were quiet:")
+
+	count := 0
+
+	for _, note := range held {
+		if count > 0 && text.Len()+len(note)+2 > maxMessageLength {
+			break
+		}
+
+		text.WriteString("\n\n" + note)
+
+		count++
+	}
+
+	return text.String(), count
+}
+
+// forgetHeld removes sent notifications from those held for a user.
+func (b *Bot) forgetHeld(userID int64, sent []string) {
+	err := b.store.UpdatePreferences(userID, func(prefs *store.Preferences) {
+		for _, note := range sent {
+			if i := slices.Index(prefs.Held, note); i >= 0 {
+				prefs.Held = slices.Delete(prefs.Held, i, i+1)
+			}
+		}
+	})
+	if err != nil {
+		b.logger.Error("failed to forget sent notifications", "error", err, "user_id", userID)
+	}
+}
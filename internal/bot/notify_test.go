@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // the tests load named zones
+
+	"github.com/lexfrei/transmission-bot/internal/store"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	t.Parallel()
+
+	berlin := &store.QuietHours{Start: 60, End: 120, Timezone: "Europe/Berlin", Hold: true}
+
+	tests := []struct {
+		name    string
+		args    string
+		current *store.QuietHours
+		want    *store.QuietHours
+		wantErr bool
+	}{
+		{name: "off", args: "off", current: berlin},
+		{name: "window in UTC", args: "22:00-07:30", want: &store.QuietHours{Start: 1320, End: 450, Timezone: "UTC"}},
+		{name: "window keeps zone and mode", args: "23:00-06:00", current: berlin,
+			want: &store.QuietHours{Start: 1380, End: 360, Timezone: "Europe/Berlin", Hold: true}},
+		{name: "window with zone", args: "01:00-02:00 Asia/Tokyo",
+			want: &store.QuietHours{Start: 60, End: 120, Timezone: "Asia/Tokyo"}},
+		{name: "silent", args: "silent", current: berlin,
+			want: &store.QuietHours{Start: 60, End: 120, Timezone: "Europe/Berlin"}},
+		{name: "hold", args: "HOLD", current: &store.QuietHours{Start: 60, End: 120, Timezone: "UTC"},
+			want: &store.QuietHours{Start: 60, End: 120, Timezone: "UTC", Hold: true}},
+		{name: "hold without quiet hours", args: "hold", wantErr: true},
+		{name: "empty window", args: "07:00-07:00", wantErr: true},
+		{name: "no dash", args: "22:00", wantErr: true},
+		{name: "bad time", args: "22:00-7pm", wantErr: true},
+		{name: "bad zone", args: "22:00-07:00 Mars/Olympus", wantErr: true},
+		{name: "no arguments", wantErr: true},
+		{name: "too many arguments", args: "22:00-07:00 UTC extra", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseQuietHours(strings.Fields(test.args), test.current)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseQuietHours(%q) error = %v, want error %v", test.args, err, test.wantErr)
+			}
+
+			if test.wantErr && !errors.Is(err, errQuietUsage) {
+				t.Errorf("parseQuietHours(%q) error = %v, want %v", test.args, err, errQuietUsage)
+			}
+
+			if (got == nil) != (test.want == nil) || (got != nil && *got != *test.want) {
+				t.Errorf("parseQuietHours(%q) = %+v, want %+v", test.args, got, test.want)
+			}
+		})
+	}
+}
+
+func TestInQuietHours(t *testing.T) {
+	t.Parallel()
+
+	night := &store.QuietHours{Start: 22 * 60, End: 7 * 60, Timezone: "UTC"}
+	lunch := &store.QuietHours{Start: 12 * 60, End: 13 * 60, Timezone: "Europe/Berlin"}
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		quiet *store.QuietHours
+		now   time.Time
+		want  bool
+	}{
+		{name: "off", now: day.Add(23 * time.Hour)},
+		{name: "before midnight", quiet: night, now: day.Add(23 * time.Hour), want: true},
+		{name: "after midnight", quiet: night, now: day.Add(3 * time.Hour), want: true},
+		{name: "start is inside", quiet: night, now: day.Add(22 * time.Hour), want: true},
+		{name: "end is outside", quiet: night, now: day.Add(7 * time.Hour)},
+		{name: "daytime", quiet: night, now: day.Add(12 * time.Hour)},
+		{name: "same-day window in zone", quiet: lunch, now: day.Add(11*time.Hour + 30*time.Minute), want: true},
+		{name: "same-day window by UTC clock", quiet: lunch, now: day.Add(12*time.Hour + 30*time.Minute)},
+		{name: "unknown zone", quiet: &store.QuietHours{Start: 0, End: 1439, Timezone: "Mars/Olympus"}, now: day},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := inQuietHours(test.quiet, test.now); got != test.want {
+				t.Errorf("inQuietHours(%s) = %v, want %v", test.now, got, test.want)
+			}
+		})
+	}
+}
+
+func TestToggleNotifyDefaults(t *testing.T) {
+	t.Parallel()
+
+	var user, admin store.Notifications
+
+	if eventOn(user, eventDisk, false) || eventOn(user, eventStalls, false) || !eventOn(user, eventCompletion, false) {
+		t.Fatal("users should get completions but not disk or stall alerts by default")
+	}
+
+	if !eventOn(admin, eventDisk, true) || !eventOn(admin, eventStalls, true) {
+		t.Fatal("admins should get disk and stall alerts by default")
+	}
+
+	for _, step := range []struct {
+		settings *store.Notifications
+		admin    bool
+		event    string
+		want     bool
+	}{
+		{settings: &user, event: eventDisk, want: true},
+		{settings: &user, event: eventDisk, want: false},
+		{settings: &user, event: eventCompletion, want: false},
+		{settings: &user, event: eventCompletion, want: true},
+		{settings: &admin, admin: true, event: eventDisk, want: false},
+		{settings: &admin, admin: true, event: eventDisk, want: true},
+	} {
+		toggleNotify(step.settings, step.event, step.admin)
+
+		if got := eventOn(*step.settings, step.event, step.admin); got != step.want {
+			t.Fatalf("after toggling %s: on = %v, want %v (settings %+v)", step.event, got, step.want, *step.settings)
+		}
+	}
+
+	if len(user.Muted) != 0 || len(user.Enabled) != 0 || len(admin.Muted) != 0 || len(admin.Enabled) != 0 {
+		t.Errorf("toggling back and forth left settings behind: user %+v, admin %+v", user, admin)
+	}
+}
+
+func TestHeldMessage(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("x", maxMessageLength/3)
+
+	tests := []struct {
+		name      string
+		held      []string
+		wantCount int
+	}{
+		{name: "one", held: []string{"Completed: Ubuntu"}, wantCount: 1},
+		{name: "all fit", held: []string{"a", "b", "c"}, wantCount: 3},
+		{name: "split", held: []string{long, long, long, long}, wantCount: 2},
+		{name: "oversized first", held: []string{strings.Repeat("x", maxMessageLength), "b"}, wantCount: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			text, count := heldMessage(test.held)
+			if count != test.wantCount {
+				t.Fatalf("heldMessage() took %d notifications, want %d", count, test.wantCount)
+			}
+
+			if count > 1 && len(text) > maxMessageLength {
+				t.Errorf("heldMessage() is %d bytes long", len(text))
+			}
+
+			for _, note := range test.held[:count] {
+				if !strings.Contains(text, note) {
+					t.Errorf("heldMessage() is missing %q", note)
+				}
+			}
+		})
+	}
+}
+
+func TestHoldKeepsNotificationsInStore(t *testing.T) {
+	t.Parallel()
+
+	stateStore, err := store.Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("store.Open() error = %v", err)
+	}
+
+	bot := &Bot{store: stateStore, logger: slog.New(slog.DiscardHandler)}
+
+	for i := range maxHeld + 2 {
+		bot.hold(1, fmt.Sprintf("note %d", i))
+	}
+
+	held := stateStore.Preferences(1).Held
+	if len(held) != maxHeld || held[0] != "note 2" {
+		t.Fatalf("held %d notifications starting with %q, want %d starting with %q", len(held), held[0], maxHeld, "note 2")
+	}
+
+	bot.forgetHeld(1, held[:3])
+
+	held = stateStore.Preferences(1).Held
+	if len(held) != maxHeld-3 || held[0] != "note 5" {
+		t.Errorf("after forgetting three, held %d starting with %q", len(held), held[0])
+	}
+}
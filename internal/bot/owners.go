@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/store"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// ownerLabelPrefix marks the Transmission label that stores the Telegram ID of the user who added a torrent.
+const ownerLabelPrefix = "tg:"
+
+func ownerLabel(userID int64) string {
+	return ownerLabelPrefix + strconv.FormatInt(userID, 10)
+}
+
+// torrentOwner extracts the Telegram user ID from the torrent's owner label.
+func torrentOwner(torrent *transmission.Torrent) (int64, bool) {
+	for _, label := range torrent.Labels {
+		idText, found := strings.CutPrefix(label, ownerLabelPrefix)
+		if !found {
+			continue
+		}
+
+		userID, err := strconv.ParseInt(idText, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		return userID, true
+	}
+
+	return 0, false
+}
+
+func isOwnedBy(torrent *transmission.Torrent, userID int64) bool {
+	owner, ok := torrentOwner(torrent)
+
+	return ok && owner == userID
+}
+
+// addOptionsFor returns the add options that tag a new torrent with its owner.
+func addOptionsFor(user *tgbotapi.User) transmission.AddOptions {
+	return transmission.AddOptions{Labels: []string{ownerLabel(user.ID)}}
+}
+
+// ownerName resolves the owner of a torrent to a display name, or "" if the torrent has no owner label.
+func (b *Bot) ownerName(torrent *transmission.Torrent) string {
+	userID, ok := torrentOwner(torrent)
+	if !ok {
+		return ""
+	}
+
+	return b.userName(userID)
+}
+
+// userName resolves a user to their @username, first name or ID, whichever is known.
+func (b *Bot) userName(userID int64) string {
+	user, known := b.store.User(userID)
+
+	switch {
+	case known && user.Username != "":
+		return "@" + user.Username
+	case known && user.FirstName != "":
+		return user.FirstName
+	default:
+		return "id " + strconv.FormatInt(userID, 10)
+	}
+}
+
+// rememberUser stores the profile of the sender of msg, and whether they have
+// a private chat with the bot that notifications can go to.
+func (b *Bot) rememberUser(msg *tgbotapi.Message) {
+	rememberErr := b.store.RememberUser(store.User{
+		ID:          msg.From.ID,
+		Username:    msg.From.UserName,
+		FirstName:   msg.From.FirstName,
+		PrivateChat: msg.Chat.IsPrivate(),
+	})
+	if rememberErr != nil {
+		b.logger.Error("failed to remember user", "error", rememberErr, "user_id", msg.From.ID)
+	}
+}
+
+func (b *Bot) recordOwnership(torrent *transmission.Torrent, user *tgbotapi.User) {
+	recordErr := b.store.RecordOwnership(store.Ownership{
+		Hash:      torrent.Hash,
+		Instance:  torrent.Instance,
+		TorrentID: torrent.ID,
+		Name:      torrent.Name,
+		UserID:    user.ID,
+		Username:  user.UserName,
+		AddedAt:   time.Now(),
+	})
+	if recordErr != nil {
+		b.logger.Error("failed to record torrent ownership", "error", recordErr,
+			"hash", torrent.Hash, "instance", torrent.Instance)
+	}
+}
@@ -0,0 +1,127 @@
+package bot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// permission is an action a role may be granted.
+type permission int
+
+const (
+	// permView allows listing torrents and reading help.
+	permView permission = iota
+	// permAdd allows adding torrents and managing the user's own ones.
+	permAdd
+	// permManageAll allows managing torrents added by anyone.
+	permManageAll
+	// permManageUsers allows granting and revoking access.
+	permManageUsers
+)
+
+// command describes a bot command and the permission needed to run it.
+type command struct {
+	name        string
+	description string
+	permission  permission
+}
+
+// commands is the ordered list of commands shown in the Telegram menu.
+//
+//nolint:gochecknoglobals // Static command table
+var commands = []command{
+	{name: "start", description: "Start the bot", permission: permView},
+	{name: "help", description: "Show help message", permission: permView},
+	{name: "list", description: "List all torrents", permission: permView},
+	{name: "mine", description: "List torrents you added", permission: permView},
+	{name: "stats", description: "Show transfer statistics", permission: permView},
+	{name: "use", description: "Select a Transmission instance", permission: permView},
+	{name: "dashboard", description: "Pin a live dashboard", permission: permView},
+	{name: "digest", description: "Schedule a daily or weekly digest", permission: permView},
+	{name: "notify", description: "Choose your notifications", permission: permView},
+	{name: "schedule", description: "Show the alt-speed schedule", permission: permView},
+	{name: "settings", description: "Show Transmission's settings", permission: permView},
+	{name: "add", description: "Add torrents from links", permission: permAdd},
+	{name: "remove", description: "Remove torrent by ID", permission: permAdd},
+	{name: "allow", description: "Grant a user access", permission: permManageUsers},
+	{name: "deny", description: "Revoke a user's access", permission: permManageUsers},
+	{name: "invite", description: "Create a single-use invite link", permission: permManageUsers},
+}
+
+// callbackPermissions maps inline button actions (the part of callback data
+// before the first ':') to the permission needed to trigger them.
+//
+//nolint:gochecknoglobals // Static callback table
+var callbackPermissions = map[string]permission{
+	callbackInfo:     permView,
+	callbackResume:   permAdd,
+	callbackNotify:   permView,
+	callbackSchedule: permManageAll,
+	callbackSettings: permManageAll,
+}
+
+func roleAllows(role config.Role, perm permission) bool {
+	switch role {
+	case config.RoleAdmin:
+		return true
+	case config.RoleUser:
+		return perm == permView || perm == permAdd
+	case config.RoleViewer:
+		return perm == permView
+	default:
+		return false
+	}
+}
+
+func commandPermission(name string) (permission, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.permission, true
+		}
+	}
+
+	return 0, false
+}
+
+// menuFor returns the commands a role is allowed to run.
+func menuFor(role config.Role) []tgbotapi.BotCommand {
+	menu := make([]tgbotapi.BotCommand, 0, len(commands))
+
+	for _, cmd := range commands {
+		if roleAllows(role, cmd.permission) {
+			menu = append(menu, tgbotapi.BotCommand{Command: cmd.name, Description: cmd.description})
+		}
+	}
+
+	return menu
+}
+
+// roleOf resolves a user's role. Roles from the config file are the baseline
+// and always win over grants made at runtime.
+func (b *Bot) roleOf(userID int64) (config.Role, bool) {
+	if role, ok := b.roles[userID]; ok {
+		return role, true
+	}
+
+	grant, ok := b.store.Grant(userID)
+
+	return grant.Role, ok
+}
+
+func (b *Bot) can(userID int64, perm permission) bool {
+	role, ok := b.roleOf(userID)
+
+	return ok && roleAllows(role, perm)
+}
+
+// canManage reports whether the user may modify the torrent: admins manage
+// everything, users only torrents carrying their owner label.
+func (b *Bot) canManage(userID int64, torrent *transmission.Torrent) bool {
+	if b.can(userID, permManageAll) {
+		return true
+	}
+
+	return b.can(userID, permAdd) && isOwnedBy(torrent, userID)
+}
@@ -0,0 +1,214 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// progressBarWidth is the number of cells in a progress bar.
+const progressBarWidth = 10
+
+// progressCards bounds the number of live progress cards, each of which
+// edits its message on every refresh, to stay within Telegram's edit limits.
+type progressCards struct {
+	slots    chan struct{}
+	interval time.Duration
+	maxWatch time.Duration
+}
+
+func newProgressCards(cfg config.ProgressConfig) *progressCards {
+	return &progressCards{
+		slots:    make(chan struct{}, cfg.MaxCards),
+		interval: cfg.Interval,
+		maxWatch: cfg.MaxWatch,
+	}
+}
+
+// acquire takes a slot for a new card, reporting false when all are in use.
+func (p *progressCards) acquire() bool {
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *progressCards) release() {
+	<-p.slots
+}
+
+// progressCard is a message kept up to date with the progress of a torrent.
+type progressCard struct {
+	client    *transmission.Client
+	torrent   *transmission.Torrent
+	chatID    int64
+	messageID int
+	text      string
+}
+
+// replyAdded confirms a newly added torrent. The reply becomes a live
+// progress card while a card slot is free; otherwise it stays as sent.
+func (b *Bot) replyAdded(ctx context.Context, msg *tgbotapi.Message, client *transmission.Client, torrent *transmission.Torrent) {
+	text := fmt.Sprintf("Torrent added:\nID: %s\nName: %s", b.torrentRef(torrent), torrent.Name)
+
+	sent, ok := b.sendReply(msg, text, nil)
+	if !ok || !b.cards.acquire() {
+		return
+	}
+
+	card := &progressCard{
+		client:    client,
+		torrent:   torrent,
+		chatID:    sent.Chat.ID,
+		messageID: sent.MessageID,
+		text:      text,
+	}
+
+	b.background.start(ctx, func(ctx context.Context) {
+		b.watchProgress(ctx, card)
+	})
+}
+
+// watchProgress refreshes a card until its torrent completes, fails or is
+// removed, or until the maximum watch time, and then leaves a final summary.
+func (b *Bot) watchProgress(ctx context.Context, card *progressCard) {
+	defer b.cards.release()
+
+	started := time.Now()
+
+	ticker := time.NewTicker(b.cards.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		torrent, err := card.client.GetTorrent(ctx, card.torrent.ID)
+
+		switch {
+		case errors.Is(err, transmission.ErrTorrentNotFound):
+			b.updateCard(card, fmt.Sprintf("Torrent removed:\nName: %s", card.torrent.Name), nil)
+
+			return
+		case err != nil:
+			// Keep the last state on screen; Transmission may be back by the next refresh.
+			b.logger.Debug("failed to refresh progress card", "error", err, "id", card.torrent.ID)
+
+			// An outage must not keep the card watched past maxWatch: close it with the last known state.
+			if time.Since(started) >= b.cards.maxWatch {
+				b.refreshCard(card, started)
+
+				return
+			}
+
+			continue
+		}
+
+		// Keep the name from the magnet link until Transmission has the real one.
+		if torrent.Name == torrent.Hash {
+			torrent.Name = card.torrent.Name
+		}
+
+		card.torrent = torrent
+
+		if b.refreshCard(card, started) {
+			return
+		}
+	}
+}
+
+// refreshCard shows the current state of a card's torrent, reporting whether
+// that state is final.
+func (b *Bot) refreshCard(card *progressCard, started time.Time) bool {
+	torrent := card.torrent
+
+	switch {
+	case torrent.Error != "":
+		b.updateCard(card, b.cardHeader("Download failed:", torrent)+"\nError: "+torrent.Error, nil)
+	case torrent.Complete():
+		b.updateCard(card, b.cardHeader("Download complete:", torrent)+
+			fmt.Sprintf("\nSize: %s, took %s", formatBytes(torrent.TotalSize), formatDuration(time.Since(started))), nil)
+	case torrent.Paused():
+		b.updateCard(card, b.cardHeader("Paused:", torrent)+"\n"+torrentStatus(torrent), cardButtons(torrent))
+	case time.Since(started) >= b.cards.maxWatch:
+		b.updateCard(card, b.cardHeader("Still downloading:", torrent)+
+			fmt.Sprintf("\n%s\nLive updates stopped after %s.", torrentStatus(torrent), formatDuration(b.cards.maxWatch)),
+			cardButtons(torrent))
+	default:
+		b.updateCard(card, b.cardHeader("Downloading:", torrent)+"\n"+progressDetails(torrent), nil)
+
+		return false
+	}
+
+	return true
+}
+
+// cardHeader starts a card with a title and the torrent's ID and name.
+func (b *Bot) cardHeader(title string, torrent *transmission.Torrent) string {
+	return fmt.Sprintf("%s\nID: %s\nName: %s", title, b.torrentRef(torrent), torrent.Name)
+}
+
+// cardButtons returns the torrent buttons for the last state of a card.
+func cardButtons(torrent *transmission.Torrent) *tgbotapi.InlineKeyboardMarkup {
+	markup := tgbotapi.NewInlineKeyboardMarkup(torrentButtons(torrent, ""))
+
+	return &markup
+}
+
+// updateCard edits a card. Unchanged text is skipped: Telegram rejects edits
+// that don't modify the message.
+func (b *Bot) updateCard(card *progressCard, text string, markup *tgbotapi.InlineKeyboardMarkup) {
+	if text == card.text && markup == nil {
+		return
+	}
+
+	err := b.edit(card.chatID, card.messageID, text, markup)
+	if err != nil {
+		b.logger.Warn("failed to update progress card", "error", err, "chat_id", card.chatID)
+
+		return
+	}
+
+	card.text = text
+}
+
+// progressDetails renders the live part of a card: a progress bar, speeds, ETA and peers.
+func progressDetails(torrent *transmission.Torrent) string {
+	return fmt.Sprintf("%s %.0f%%\n↓ %s/s ↑ %s/s\nETA: %s, peers: %d",
+		progressBar(torrent.PercentDone),
+		torrent.PercentDone*percentMultiply,
+		formatBytes(torrent.DownloadRate),
+		formatBytes(torrent.UploadRate),
+		formatDuration(torrent.ETA),
+		torrent.PeersConnected,
+	)
+}
+
+// progressBar draws a fraction between 0 and 1, as in ▓▓▓▓░░░░░░.
+func progressBar(fraction float64) string {
+	filled := int(fraction * progressBarWidth)
+	filled = max(0, min(filled, progressBarWidth))
+
+	return strings.Repeat("▓", filled) + strings.Repeat("░", progressBarWidth-filled)
+}
+
+// formatDuration renders a duration to the second, or "unknown" when negative.
+func formatDuration(duration time.Duration) string {
+	if duration < 0 {
+		return "unknown"
+	}
+
+	return duration.Round(time.Second).String()
+}
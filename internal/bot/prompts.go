@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// promptTTL is how long the bot waits for the answer to a question.
+const promptTTL = 5 * time.Minute
+
+// promptKey identifies a question by the message that asked it.
+type promptKey struct {
+	chatID    int64
+	messageID int
+}
+
+// promptAnswer handles the reply to a question.
+type promptAnswer func(ctx context.Context, msg *tgbotapi.Message)
+
+// prompt is a question waiting for the reply of the user who caused it.
+type prompt struct {
+	userID     int64
+	permission permission
+	expires    time.Time
+	answer     promptAnswer
+}
+
+// prompts holds the questions waiting for an answer, such as a new value for
+// a setting. Answers are replies to the question, which Telegram delivers
+// even in groups where the bot only sees messages addressed to it.
+type prompts struct {
+	mu      sync.Mutex
+	pending map[promptKey]prompt
+}
+
+func newPrompts() *prompts {
+	return &prompts{pending: make(map[promptKey]prompt)}
+}
+
+func (p *prompts) add(key promptKey, pending prompt) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+
+	for existing, old := range p.pending {
+		if now.After(old.expires) {
+			delete(p.pending, existing)
+		}
+	}
+
+	p.pending[key] = pending
+}
+
+// take removes and returns the question msg answers, if msg is a timely
+// reply to one by the user it was asked of.
+func (p *prompts) take(msg *tgbotapi.Message) (prompt, bool) {
+	if msg.ReplyToMessage == nil || msg.From == nil {
+		return prompt{}, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := promptKey{chatID: msg.Chat.ID, messageID: msg.ReplyToMessage.MessageID}
+
+	pending, ok := p.pending[key]
+	if !ok || pending.userID != msg.From.ID {
+		return prompt{}, false
+	}
+
+	delete(p.pending, key)
+
+	return pending, time.Now().Before(pending.expires)
+}
+
+// ask replies to the message of a pressed button with a question for the
+// user who pressed it; their reply goes to answer if they still hold perm.
+func (b *Bot) ask(query *tgbotapi.CallbackQuery, question string, perm permission, answer promptAnswer) {
+	if query.Message == nil {
+		b.answerCallback(query, "")
+
+		return
+	}
+
+	sent, ok := b.sendReply(query.Message, question, tgbotapi.ForceReply{ForceReply: true})
+	if !ok {
+		b.answerCallback(query, "Failed to ask. Please try again.")
+
+		return
+	}
+
+	b.answerCallback(query, "")
+
+	b.prompts.add(promptKey{chatID: sent.Chat.ID, messageID: sent.MessageID}, prompt{
+		userID:     query.From.ID,
+		permission: perm,
+		expires:    time.Now().Add(promptTTL),
+		answer:     answer,
+	})
+}
+
+// answerPrompt passes a reply to the question it answers and reports whether
+// it was one.
+func (b *Bot) answerPrompt(ctx context.Context, msg *tgbotapi.Message) bool {
+	pending, ok := b.prompts.take(msg)
+	if !ok {
+		return false
+	}
+
+	if !b.can(msg.From.ID, pending.permission) {
+		b.reply(msg, msgNotPermitted)
+
+		return true
+	}
+
+	pending.answer(ctx, msg)
+
+	return true
+}
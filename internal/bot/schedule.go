@@ -0,0 +1,245 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// Callback action of the /schedule buttons; the data is
+// "schedule:instance:change", where change is one of the constants below or
+// "day" followed by a weekday number.
+const (
+	callbackSchedule = "schedule"
+	scheduleToggle   = "toggle"
+	scheduleBegin    = "begin"
+	scheduleEnd      = "end"
+	scheduleDay      = "day"
+)
+
+// scheduleDaysPerRow splits the day picker over two rows.
+const scheduleDaysPerRow = 4
+
+// handleSchedule shows the alt-speed schedule of the target instance, with
+// buttons to change it for admins, followed by the bot's own windows.
+func (b *Bot) handleSchedule(ctx context.Context, msg *tgbotapi.Message) {
+	client := b.targetInstance(msg)
+
+	text, markup, err := b.renderSchedule(ctx, client, b.can(msg.From.ID, permManageAll))
+	if err != nil {
+		b.logger.Error("failed to get alt-speed schedule", "error", err, "instance", client.Name())
+		b.reply(msg, b.instanceFailure(client, "Failed to get the schedule", err))
+
+		return
+	}
+
+	if markup == nil {
+		b.reply(msg, text)
+
+		return
+	}
+
+	b.replyWithMarkup(msg, text, markup)
+}
+
+// renderSchedule describes the alt-speed schedule of an instance; markup is
+// nil unless editable.
+func (b *Bot) renderSchedule(
+	ctx context.Context,
+	client *transmission.Client,
+	editable bool,
+) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	schedule, err := client.AltSpeedSchedule(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var text strings.Builder
+
+	text.WriteString("Alt-speed schedule")
+
+	if b.multiInstance() {
+		text.WriteString(" of " + client.Name())
+	}
+
+	fmt.Fprintf(&text, ":\nStatus: %s\nTime: %s-%s (Transmission's local time)\nDays: %s\nAlt limits: %s\n",
+		onOff(schedule.Enabled), formatClock(schedule.Begin), formatClock(schedule.End),
+		formatDays(schedule.Days), formatLimits(transmission.SpeedLimits{Down: schedule.Down, Up: schedule.Up}))
+
+	if len(b.bandwidth.windows) > 0 {
+		text.WriteString("\n" + b.bandwidth.describe(time.Now()))
+	}
+
+	if !editable {
+		return strings.TrimSpace(text.String()), nil, nil
+	}
+
+	return strings.TrimSpace(text.String()), scheduleButtons(client.Name(), schedule), nil
+}
+
+// scheduleButtons returns an on/off switch, a day picker and buttons asking for new times.
+func scheduleButtons(instance string, schedule *transmission.AltSpeedSchedule) *tgbotapi.InlineKeyboardMarkup {
+	data := func(change string) string {
+		return callbackSchedule + ":" + instance + ":" + change
+	}
+
+	toggle := "Turn on"
+	if schedule.Enabled {
+		toggle = "Turn off"
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(toggle, data(scheduleToggle))),
+	}
+
+	var days []tgbotapi.InlineKeyboardButton
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		label := day.String()[:len("Mon")]
+		if schedule.Days&(1<<day) != 0 {
+			label = "✓ " + label
+		}
+
+		days = append(days, tgbotapi.NewInlineKeyboardButtonData(label, data(scheduleDay+strconv.Itoa(int(day)))))
+	}
+
+	rows = append(rows, days[:scheduleDaysPerRow], days[scheduleDaysPerRow:],
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Begin "+formatClock(schedule.Begin), data(scheduleBegin)),
+			tgbotapi.NewInlineKeyboardButtonData("End "+formatClock(schedule.End), data(scheduleEnd)),
+		))
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	return &markup
+}
+
+// handleScheduleCallback applies a /schedule button: it switches the
+// schedule, toggles a day, or asks for a new begin or end time.
+func (b *Bot) handleScheduleCallback(ctx context.Context, query *tgbotapi.CallbackQuery, args string) {
+	name, change, _ := strings.Cut(args, ":")
+
+	client, ok := b.instance(name)
+	if !ok {
+		b.answerCallback(query, "Unknown instance.")
+
+		return
+	}
+
+	if change == scheduleBegin || change == scheduleEnd {
+		message := query.Message
+
+		b.ask(query, fmt.Sprintf("Send the new %s time as HH:MM, like 01:30.", change), permManageAll,
+			func(ctx context.Context, msg *tgbotapi.Message) {
+				b.answerScheduleTime(ctx, msg, client, change, message)
+			})
+
+		return
+	}
+
+	err := b.changeSchedule(ctx, client, func(schedule *transmission.AltSpeedSchedule) bool {
+		day, dayErr := strconv.Atoi(strings.TrimPrefix(change, scheduleDay))
+
+		switch {
+		case change == scheduleToggle:
+			schedule.Enabled = !schedule.Enabled
+		case strings.HasPrefix(change, scheduleDay) && dayErr == nil && day >= 0 && day <= int(time.Saturday):
+			schedule.Days ^= 1 << day
+		default:
+			return false
+		}
+
+		return true
+	})
+	if err != nil {
+		b.logger.Error("failed to change alt-speed schedule", "error", err, "instance", client.Name())
+		b.answerCallback(query, userError("Failed to change the schedule", err))
+
+		return
+	}
+
+	b.logger.Info("alt-speed schedule changed", "change", change, "instance", client.Name(), "user_id", query.From.ID)
+	b.answerCallback(query, "")
+	b.refreshSchedule(ctx, client, query.Message)
+}
+
+// answerScheduleTime sets the begin or end time sent in reply to the question.
+func (b *Bot) answerScheduleTime(
+	ctx context.Context,
+	msg *tgbotapi.Message,
+	client *transmission.Client,
+	change string,
+	scheduleMessage *tgbotapi.Message,
+) {
+	minutes, err := config.ParseClock(strings.TrimSpace(msg.Text))
+	if err != nil {
+		b.reply(msg, "Invalid time: please use HH:MM, like 01:30.")
+
+		return
+	}
+
+	err = b.changeSchedule(ctx, client, func(schedule *transmission.AltSpeedSchedule) bool {
+		if change == scheduleBegin {
+			schedule.Begin = minutes
+		} else {
+			schedule.End = minutes
+		}
+
+		return true
+	})
+	if err != nil {
+		b.logger.Error("failed to change alt-speed schedule", "error", err, "instance", client.Name())
+		b.reply(msg, b.instanceFailure(client, "Failed to change the schedule", err))
+
+		return
+	}
+
+	b.logger.Info("alt-speed schedule changed", "change", change, "instance", client.Name(), "user_id", msg.From.ID)
+	b.reply(msg, fmt.Sprintf("The alt-speed schedule now %ss at %s.", change, formatClock(minutes)))
+	b.refreshSchedule(ctx, client, scheduleMessage)
+}
+
+// changeSchedule reads the alt-speed schedule, applies update and writes it
+// back unless update reports that nothing changed.
+func (b *Bot) changeSchedule(
+	ctx context.Context,
+	client *transmission.Client,
+	update func(*transmission.AltSpeedSchedule) bool,
+) error {
+	schedule, err := client.AltSpeedSchedule(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !update(schedule) {
+		return nil
+	}
+
+	return client.SetAltSpeedSchedule(ctx, *schedule)
+}
+
+// refreshSchedule shows the current schedule in a /schedule message.
+func (b *Bot) refreshSchedule(ctx context.Context, client *transmission.Client, message *tgbotapi.Message) {
+	if message == nil {
+		return
+	}
+
+	text, markup, err := b.renderSchedule(ctx, client, true)
+	if err != nil {
+		b.logger.Warn("failed to refresh schedule", "error", err, "instance", client.Name())
+
+		return
+	}
+
+	editErr := b.edit(message.Chat.ID, message.MessageID, text, markup)
+	if editErr != nil && !isNotModified(editErr) {
+		b.logger.Warn("failed to update schedule", "error", editErr, "instance", client.Name())
+	}
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// anyWeekday makes a cronSpec match every day of the week.
+const anyWeekday time.Weekday = -1
+
+// cronSpec is a cron-like point in time, matched to the minute: a time of
+// day in a location, on every day or on one day of the week.
+type cronSpec struct {
+	minute   int
+	hour     int
+	weekday  time.Weekday
+	location *time.Location
+}
+
+// matches reports whether minute falls on the spec, in the spec's location.
+func (c cronSpec) matches(minute time.Time) bool {
+	local := minute.In(c.location)
+
+	return local.Minute() == c.minute && local.Hour() == c.hour &&
+		(c.weekday == anyWeekday || local.Weekday() == c.weekday)
+}
+
+// scheduledJob runs once at the start of every minute and decides for itself
+// whether anything is due at that minute.
+type scheduledJob func(ctx context.Context, minute time.Time)
+
+// scheduler runs jobs at every wall-clock minute. Each minute is run at most
+// once; minutes missed while the bot was down or the host slept are skipped
+// rather than replayed.
+type scheduler struct {
+	mu   sync.Mutex
+	jobs []scheduledJob
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{}
+}
+
+// register adds a job; jobs registered after run has started are picked up
+// from the next minute.
+func (s *scheduler) register(job scheduledJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs = append(s.jobs, job)
+}
+
+// run calls the jobs at every minute until ctx is cancelled. Jobs run one
+// after another and should be quick; slow work belongs in a goroutine.
+func (s *scheduler) run(ctx context.Context) {
+	next := time.Now().Truncate(time.Minute).Add(time.Minute)
+
+	for {
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-timer.C:
+		}
+
+		s.mu.Lock()
+		jobs := append([]scheduledJob(nil), s.jobs...)
+		s.mu.Unlock()
+
+		for _, job := range jobs {
+			job(ctx, next)
+		}
+
+		next = next.Add(time.Minute)
+
+		if now := time.Now(); next.Before(now) {
+			next = now.Truncate(time.Minute).Add(time.Minute)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// maxSendAttempts bounds how often a request is tried before giving up.
+	maxSendAttempts = 5
+
+	// chatSendBurst is the number of messages a chat may receive back to back.
+	chatSendBurst = 3
+
+	sendBackoffBase = time.Second
+	sendBackoffMax  = 30 * time.Second
+)
+
+var errSenderStopped = errors.New("sender stopped")
+
+// sender is the single path for outgoing Telegram requests. Callers first wait
+// for their chat's rate limit, then queue the request; one dispatcher applies
+// the global rate and makes exactly one attempt per queued request, so it never
+// sleeps on behalf of a single chat. Retries happen in the caller: a failed
+// request parks its chat until the retry is due and is then queued again.
+// A single dispatcher keeps messages to the same chat in order.
+type sender struct {
+	queue    chan outgoing
+	stopped  chan struct{}
+	global   *rate.Limiter
+	chatRate rate.Limit
+	logger   *slog.Logger
+
+	mu    sync.Mutex
+	chats map[int64]*chatSends
+}
+
+// chatSends is the per-chat send state: the chat's rate limit and the time
+// before which nothing may be sent to it after a failed request.
+type chatSends struct {
+	limiter     *rate.Limiter
+	parkedUntil time.Time
+}
+
+// outgoing is a queued request. result receives exactly one value once the
+// dispatcher has taken the request.
+type outgoing struct {
+	call   func() error
+	result chan error
+}
+
+func newSender(perSecond, chatPerMinute float64, logger *slog.Logger) *sender {
+	return &sender{
+		queue:    make(chan outgoing),
+		stopped:  make(chan struct{}),
+		global:   rate.NewLimiter(rate.Limit(perSecond), 1),
+		chatRate: rate.Limit(chatPerMinute / time.Minute.Seconds()),
+		logger:   logger,
+		chats:    make(map[int64]*chatSends),
+	}
+}
+
+// run dispatches queued requests until ctx is cancelled.
+func (s *sender) run(ctx context.Context) {
+	defer close(s.stopped)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-s.queue:
+			waitErr := s.global.Wait(ctx)
+			if waitErr != nil {
+				item.result <- fmt.Errorf("waiting for send rate: %w", waitErr)
+
+				continue
+			}
+
+			item.result <- item.call()
+		}
+	}
+}
+
+// do performs call on behalf of chatID once both rate limits allow it, and
+// returns its final error after any retries. call must be safe to repeat:
+// edits, pins and deletions are, so network errors are retried too.
+func (s *sender) do(chatID int64, call func() error) error {
+	return s.request(chatID, call, true)
+}
+
+// doOnce is do for requests that create something, such as a new message.
+// A network error may hide a request Telegram already carried out, so only
+// errors Telegram answered with are retried; this avoids duplicate messages.
+func (s *sender) doOnce(chatID int64, call func() error) error {
+	return s.request(chatID, call, false)
+}
+
+func (s *sender) request(chatID int64, call func() error, retryNetwork bool) error {
+	backoff := sendBackoffBase
+
+	for attempt := 1; ; attempt++ {
+		err := s.attempt(chatID, call)
+		if err == nil || attempt == maxSendAttempts || errors.Is(err, errSenderStopped) {
+			return err
+		}
+
+		delay, retry := retryDelay(err, backoff, retryNetwork)
+		if !retry {
+			return err
+		}
+
+		s.logger.Warn("telegram request failed, retrying",
+			"error", err, "chat_id", chatID, "attempt", attempt, "delay", delay)
+
+		s.park(chatID, delay)
+
+		backoff = min(backoff*2, sendBackoffMax)
+	}
+}
+
+// attempt waits for the chat's rate limit and any parking, then hands call to
+// the dispatcher and returns its error.
+func (s *sender) attempt(chatID int64, call func() error) error {
+	timer := time.NewTimer(s.chatDelay(chatID))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-s.stopped:
+		return errSenderStopped
+	}
+
+	item := outgoing{call: call, result: make(chan error, 1)}
+
+	select {
+	case s.queue <- item:
+		return <-item.result
+	case <-s.stopped:
+		return errSenderStopped
+	}
+}
+
+// chatDelay reserves a send for chatID and returns how long to wait for it.
+func (s *sender) chatDelay(chatID int64) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat := s.chat(chatID)
+
+	return max(chat.limiter.Reserve().Delay(), time.Until(chat.parkedUntil))
+}
+
+// park holds back every request to chatID for delay, so that a retry_after
+// from Telegram also applies to the messages queued behind the failed one.
+func (s *sender) park(chatID int64, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat := s.chat(chatID)
+
+	until := time.Now().Add(delay)
+	if until.After(chat.parkedUntil) {
+		chat.parkedUntil = until
+	}
+}
+
+// chat returns the state for chatID; s.mu must be held.
+func (s *sender) chat(chatID int64) *chatSends {
+	chat, ok := s.chats[chatID]
+	if !ok {
+		chat = &chatSends{limiter: rate.NewLimiter(s.chatRate, chatSendBurst)}
+		s.chats[chatID] = chat
+	}
+
+	return chat
+}
+
+// retryDelay reports whether a failed request is worth retrying and how long
+// to wait first: Telegram's retry_after for 429, backoff for server errors and,
+// when retryNetwork is set, network errors. Other API errors, such as a
+// deleted chat, are final.
+func retryDelay(err error, backoff time.Duration, retryNetwork bool) (time.Duration, bool) {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		if apiErr.RetryAfter > 0 {
+			return time.Duration(apiErr.RetryAfter) * time.Second, true
+		}
+
+		return backoff, apiErr.Code >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return backoff, retryNetwork
+	}
+
+	return 0, false
+}
@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	backoff := 2 * time.Second
+	timeout := &net.OpError{Op: "read", Err: errors.New("i/o timeout")}
+
+	tests := []struct {
+		name         string
+		err          error
+		retryNetwork bool
+		wantDelay    time.Duration
+		wantRetry    bool
+	}{
+		{name: "flood control", err: &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 7}},
+			wantDelay: 7 * time.Second, wantRetry: true},
+		{name: "server error", err: &tgbotapi.Error{Code: 502}, wantDelay: backoff, wantRetry: true},
+		{name: "bad request", err: &tgbotapi.Error{Code: 400}, wantDelay: backoff},
+		{name: "network error retried", err: timeout, retryNetwork: true, wantDelay: backoff, wantRetry: true},
+		{name: "network error not retried", err: timeout, wantDelay: backoff},
+		{name: "other error", err: errors.New("boom")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			delay, retry := retryDelay(test.err, backoff, test.retryNetwork)
+			if delay != test.wantDelay || retry != test.wantRetry {
+				t.Errorf("retryDelay() = %v, %v; want %v, %v", delay, retry, test.wantDelay, test.wantRetry)
+			}
+		})
+	}
+}
+
+func TestSenderParksOnlyTheFloodedChat(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	sender := newSender(1000, 6000, slog.New(slog.DiscardHandler))
+	go sender.run(ctx)
+
+	flooded := make(chan error, 1)
+
+	go func() {
+		calls := 0
+
+		flooded <- sender.doOnce(1, func() error {
+			calls++
+			if calls == 1 {
+				return &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1}}
+			}
+
+			return nil
+		})
+	}()
+
+	// Let the first attempt fail and park chat 1.
+	deadline := time.Now().Add(time.Second)
+	for !sender.parked(1) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	started := time.Now()
+
+	err := sender.do(2, func() error { return nil })
+	if err != nil {
+		t.Fatalf("do() for another chat error = %v", err)
+	}
+
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("another chat waited %v behind the flooded one", elapsed)
+	}
+
+	err = <-flooded
+	if err != nil {
+		t.Errorf("flooded chat error = %v, want retry to succeed", err)
+	}
+}
+
+func (s *sender) parked(chatID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat, ok := s.chats[chatID]
+
+	return ok && time.Now().Before(chat.parkedUntil)
+}
+
+func TestSenderDoesNotResendAfterNetworkError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	sender := newSender(1000, 6000, slog.New(slog.DiscardHandler))
+	go sender.run(ctx)
+
+	calls := 0
+
+	err := sender.doOnce(1, func() error {
+		calls++
+
+		return &net.OpError{Op: "read", Err: errors.New("i/o timeout")}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("doOnce() = %v after %d calls; want the error after 1 call", err, calls)
+	}
+}
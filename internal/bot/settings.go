@@ -0,0 +1,487 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+	"github.com/lexfrei/transmission-bot/internal/store"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// Callback action of the /settings buttons; the data is "settings:instance:key".
+const callbackSettings = "settings"
+
+// auditShown is how many changes /settings log lists.
+const auditShown = 15
+
+// errUnknownSetting is returned for a settings.editable entry /settings doesn't show.
+var errUnknownSetting = errors.New("settings.editable entries must be Transmission settings shown by /settings")
+
+// settingsButtonsPerRow keeps the labels of the /settings buttons readable.
+const settingsButtonsPerRow = 2
+
+// settingKind is how a setting is shown and changed.
+type settingKind int
+
+const (
+	// settingSwitch is turned on and off by its button.
+	settingSwitch settingKind = iota
+	// settingCount is a whole number the bot asks for.
+	settingCount
+	// settingRatio is a decimal number the bot asks for.
+	settingRatio
+	// settingPath is a directory on the Transmission host the bot asks for.
+	settingPath
+	// settingEncryption cycles through encryptionModes.
+	settingEncryption
+)
+
+// setting is a Transmission session setting shown by /settings.
+type setting struct {
+	// key is the RPC name of the setting.
+	key   string
+	label string
+	kind  settingKind
+	// minimum and maximum bound a settingCount; a maximum of 0 is no bound.
+	// Ports and the counts Transmission keeps in 16 bits go up to 65535.
+	minimum int
+	maximum int
+}
+
+// settingGroup is a titled section of /settings.
+type settingGroup struct {
+	name     string
+	settings []setting
+}
+
+// settingGroups are the settings /settings shows, in order, and so the ones
+// settings.editable may list; New refuses any other.
+//
+//nolint:gochecknoglobals // static settings table
+var settingGroups = []settingGroup{
+	{name: "Peers", settings: []setting{
+		{key: "peer-limit-global", label: "Peer limit", kind: settingCount, maximum: math.MaxUint16},
+		{key: "peer-limit-per-torrent", label: "Peers per torrent", kind: settingCount, maximum: math.MaxUint16},
+		{key: "peer-port", label: "Peer port", kind: settingCount, minimum: 1, maximum: math.MaxUint16},
+		{key: "port-forwarding-enabled", label: "Port forwarding", kind: settingSwitch},
+		{key: "dht-enabled", label: "DHT", kind: settingSwitch},
+		{key: "pex-enabled", label: "PEX", kind: settingSwitch},
+		{key: "lpd-enabled", label: "LPD", kind: settingSwitch},
+		{key: "encryption", label: "Encryption", kind: settingEncryption},
+	}},
+	{name: "Seeding", settings: []setting{
+		{key: "seedRatioLimited", label: "Ratio limit", kind: settingSwitch},
+		{key: "seedRatioLimit", label: "Ratio", kind: settingRatio},
+		{key: "idle-seeding-limit-enabled", label: "Idle limit", kind: settingSwitch},
+		{key: "idle-seeding-limit", label: "Idle minutes", kind: settingCount, maximum: math.MaxUint16},
+	}},
+	{name: "Queue", settings: []setting{
+		{key: "download-queue-enabled", label: "Download queue", kind: settingSwitch},
+		{key: "download-queue-size", label: "Downloads at once", kind: settingCount},
+		{key: "seed-queue-enabled", label: "Seed queue", kind: settingSwitch},
+		{key: "seed-queue-size", label: "Seeds at once", kind: settingCount},
+		{key: "queue-stalled-enabled", label: "Skip stalled", kind: settingSwitch},
+		{key: "queue-stalled-minutes", label: "Stalled after minutes", kind: settingCount},
+	}},
+	{name: "Files", settings: []setting{
+		{key: "download-dir", label: "Download dir", kind: settingPath},
+		{key: "incomplete-dir-enabled", label: "Incomplete dir", kind: settingSwitch},
+		{key: "incomplete-dir", label: "Incomplete path", kind: settingPath},
+		{key: "rename-partial-files", label: "Add .part", kind: settingSwitch},
+		{key: "start-added-torrents", label: "Start added", kind: settingSwitch},
+	}},
+}
+
+// encryptionModes are the values of the encryption setting, in the order
+// its button goes through them.
+//
+//nolint:gochecknoglobals // static value list
+var encryptionModes = []string{"preferred", "required", "tolerated"}
+
+// hint describes the value the bot asks for.
+func (s *setting) hint() string {
+	switch s.kind {
+	case settingCount:
+		if s.maximum > 0 {
+			return fmt.Sprintf("a whole number from %d to %d", s.minimum, s.maximum)
+		}
+
+		return "a whole number, like 50"
+	case settingRatio:
+		return "a number, like 1.5"
+	case settingPath:
+		return "an absolute path, like /downloads/incomplete"
+	default:
+		return ""
+	}
+}
+
+// parse reads a value sent for the setting.
+func (s *setting) parse(text string) (any, bool) {
+	switch s.kind {
+	case settingCount:
+		count, err := strconv.Atoi(text)
+
+		return count, err == nil && count >= s.minimum && (s.maximum == 0 || count <= s.maximum)
+	case settingRatio:
+		ratio, err := strconv.ParseFloat(text, 64)
+
+		return ratio, err == nil && ratio >= 0 && !math.IsInf(ratio, 0)
+	case settingPath:
+		return path.Clean(text), path.IsAbs(text)
+	default:
+		return nil, false
+	}
+}
+
+func findSetting(key string) (*setting, bool) {
+	for i := range settingGroups {
+		for j := range settingGroups[i].settings {
+			if settingGroups[i].settings[j].key == key {
+				return &settingGroups[i].settings[j], true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// settingKeys returns the RPC names of every setting /settings shows.
+func settingKeys() []string {
+	var keys []string
+
+	for _, group := range settingGroups {
+		for _, item := range group.settings {
+			keys = append(keys, item.key)
+		}
+	}
+
+	return keys
+}
+
+// formatSetting shows a session value as it came from Transmission or as it is sent.
+func formatSetting(value any) string {
+	switch typed := value.(type) {
+	case bool:
+		return onOff(typed)
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(typed)
+	case string:
+		if typed == "" {
+			return "none"
+		}
+
+		return typed
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// handleSettings shows the session settings of the target instance, with
+// buttons for admins to change the editable ones. "/settings log" lists the
+// latest changes instead.
+func (b *Bot) handleSettings(ctx context.Context, msg *tgbotapi.Message) {
+	editable := b.can(msg.From.ID, permManageAll)
+
+	if strings.EqualFold(strings.TrimSpace(msg.CommandArguments()), "log") {
+		if !editable {
+			b.reply(msg, msgNotPermitted)
+
+			return
+		}
+
+		b.reply(msg, b.renderAuditLog())
+
+		return
+	}
+
+	client := b.targetInstance(msg)
+
+	text, markup, err := b.renderSettings(ctx, client, editable)
+	if err != nil {
+		b.logger.Error("failed to get settings", "error", err, "instance", client.Name())
+		b.reply(msg, b.instanceFailure(client, "Failed to get the settings", err))
+
+		return
+	}
+
+	if markup == nil {
+		b.reply(msg, text)
+
+		return
+	}
+
+	b.replyWithMarkup(msg, text, markup)
+}
+
+// renderSettings lists the settings of an instance by group; markup is nil
+// unless editable and some setting may be changed.
+func (b *Bot) renderSettings(
+	ctx context.Context,
+	client *transmission.Client,
+	editable bool,
+) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	values, err := client.SessionValues(ctx, settingKeys())
+	if err != nil {
+		return "", nil, err
+	}
+
+	var (
+		text    strings.Builder
+		buttons []tgbotapi.InlineKeyboardButton
+	)
+
+	text.WriteString("Settings")
+
+	if b.multiInstance() {
+		text.WriteString(" of " + client.Name())
+	}
+
+	text.WriteString(":\n")
+
+	for _, group := range settingGroups {
+		fmt.Fprintf(&text, "\n%s:\n", group.name)
+
+		for _, item := range group.settings {
+			value, ok := values[item.key]
+			if !ok {
+				continue
+			}
+
+			shown := item.label + ": " + formatSetting(value)
+			text.WriteString(shown + "\n")
+
+			if editable && b.settingEditable(item.key) {
+				buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(shown,
+					callbackSettings+":"+client.Name()+":"+item.key))
+			}
+		}
+	}
+
+	if len(buttons) == 0 {
+		return strings.TrimSpace(text.String()), nil, nil
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(slices.Collect(slices.Chunk(buttons, settingsButtonsPerRow))...)
+
+	return strings.TrimSpace(text.String()), &markup, nil
+}
+
+// editableSettings returns the settings admins may change, refusing any
+// entry that isn't one of settingGroups.
+func editableSettings(cfg config.SettingsConfig) (map[string]struct{}, error) {
+	editable := make(map[string]struct{}, len(cfg.Editable))
+
+	for _, key := range cfg.Editable {
+		_, known := findSetting(key)
+		if !known {
+			return nil, fmt.Errorf("%w: %q", errUnknownSetting, key)
+		}
+
+		editable[key] = struct{}{}
+	}
+
+	return editable, nil
+}
+
+func (b *Bot) settingEditable(key string) bool {
+	_, ok := b.editable[key]
+
+	return ok
+}
+
+// handleSettingsCallback applies a /settings button: it flips a switch,
+// moves encryption to the next mode, or asks for a new value.
+func (b *Bot) handleSettingsCallback(ctx context.Context, query *tgbotapi.CallbackQuery, args string) {
+	name, key, _ := strings.Cut(args, ":")
+
+	client, ok := b.instance(name)
+	if !ok {
+		b.answerCallback(query, "Unknown instance.")
+
+		return
+	}
+
+	item, known := findSetting(key)
+	if !known || !b.settingEditable(key) {
+		b.answerCallback(query, "This setting can't be changed.")
+
+		return
+	}
+
+	var next func(current any) any
+
+	switch item.kind {
+	case settingSwitch:
+		next = func(current any) any {
+			enabled, _ := current.(bool)
+
+			return !enabled
+		}
+	case settingEncryption:
+		next = nextEncryption
+	case settingCount, settingRatio, settingPath:
+		message := query.Message
+
+		b.ask(query, fmt.Sprintf("Send the new %s: %s.", strings.ToLower(item.label), item.hint()), permManageAll,
+			func(ctx context.Context, msg *tgbotapi.Message) {
+				b.answerSetting(ctx, msg, client, item, message)
+			})
+
+		return
+	}
+
+	_, err := b.changeSetting(ctx, query.From.ID, client, item, next)
+	if err != nil {
+		b.logger.Error("failed to change setting", "error", err, "setting", key, "instance", client.Name())
+		b.answerCallback(query, userError("Failed to change the setting", err))
+
+		return
+	}
+
+	b.answerCallback(query, "")
+	b.refreshSettings(ctx, client, query.Message)
+}
+
+// nextEncryption returns the encryption mode after current.
+func nextEncryption(current any) any {
+	mode, _ := current.(string)
+
+	return encryptionModes[(slices.Index(encryptionModes, mode)+1)%len(encryptionModes)]
+}
+
+// answerSetting sets the value sent in reply to the question.
+func (b *Bot) answerSetting(
+	ctx context.Context,
+	msg *tgbotapi.Message,
+	client *transmission.Client,
+	item *setting,
+	settingsMessage *tgbotapi.Message,
+) {
+	value, ok := item.parse(strings.TrimSpace(msg.Text))
+	if !ok {
+		b.reply(msg, "Invalid value: please send "+item.hint()+".")
+
+		return
+	}
+
+	shown, err := b.changeSetting(ctx, msg.From.ID, client, item, func(any) any { return value })
+	if err != nil {
+		b.logger.Error("failed to change setting", "error", err, "setting", item.key, "instance", client.Name())
+		b.reply(msg, b.instanceFailure(client, "Failed to change the setting", err))
+
+		return
+	}
+
+	b.reply(msg, fmt.Sprintf("%s is now %s.", item.label, shown))
+	b.refreshSettings(ctx, client, settingsMessage)
+}
+
+// changeSetting reads a setting, sets it to what next makes of it and
+// records the change in the audit log. It returns the new value as shown.
+func (b *Bot) changeSetting(
+	ctx context.Context,
+	userID int64,
+	client *transmission.Client,
+	item *setting,
+	next func(current any) any,
+) (string, error) {
+	values, err := client.SessionValues(ctx, []string{item.key})
+	if err != nil {
+		return "", err
+	}
+
+	current, ok := values[item.key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s is not reported", transmission.ErrUnknownSetting, item.key)
+	}
+
+	value := next(current)
+	old, updated := formatSetting(current), formatSetting(value)
+
+	if old == updated {
+		return updated, nil
+	}
+
+	err = client.SetSessionValue(ctx, item.key, value)
+	if err != nil {
+		return "", err
+	}
+
+	b.logger.Info("setting changed",
+		"setting", item.key,
+		"old", old,
+		"new", updated,
+		"instance", client.Name(),
+		"user_id", userID,
+	)
+
+	auditErr := b.store.RecordAudit(store.AuditEntry{
+		Time:     time.Now(),
+		UserID:   userID,
+		Instance: client.Name(),
+		Setting:  item.key,
+		Old:      old,
+		New:      updated,
+	})
+	if auditErr != nil {
+		b.logger.Error("failed to record setting change", "error", auditErr, "setting", item.key)
+	}
+
+	return updated, nil
+}
+
+// refreshSettings shows the current settings in a /settings message.
+func (b *Bot) refreshSettings(ctx context.Context, client *transmission.Client, message *tgbotapi.Message) {
+	if message == nil {
+		return
+	}
+
+	text, markup, err := b.renderSettings(ctx, client, true)
+	if err != nil {
+		b.logger.Warn("failed to refresh settings", "error", err, "instance", client.Name())
+
+		return
+	}
+
+	editErr := b.edit(message.Chat.ID, message.MessageID, text, markup)
+	if editErr != nil && !isNotModified(editErr) {
+		b.logger.Warn("failed to update settings", "error", editErr, "instance", client.Name())
+	}
+}
+
+// renderAuditLog lists the latest setting changes, newest first.
+func (b *Bot) renderAuditLog() string {
+	entries := b.store.AuditLog(auditShown)
+	if len(entries) == 0 {
+		return "No settings have been changed yet."
+	}
+
+	var text strings.Builder
+
+	text.WriteString("Latest setting changes:\n")
+
+	for _, entry := range entries {
+		fmt.Fprintf(&text, "\n%s %s", entry.Time.UTC().Format("2006-01-02 15:04"), b.userName(entry.UserID))
+
+		if b.multiInstance() {
+			text.WriteString(" on " + entry.Instance)
+		}
+
+		fmt.Fprintf(&text, ": %s %s → %s", entry.Setting, entry.Old, entry.New)
+	}
+
+	return text.String() + "\n\nTimes are in UTC."
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+)
+
+func TestEditableSettings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		editable []string
+		wantErr  bool
+	}{
+		{name: "none"},
+		{name: "shown settings", editable: []string{"dht-enabled", "encryption", "seedRatioLimit", "download-dir"}},
+		{name: "every shown setting", editable: settingKeys()},
+		{name: "unknown setting", editable: []string{"dht-enabled", "alt-speed-enabled"}, wantErr: true},
+		{name: "wrong case", editable: []string{"DHT-enabled"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			editable, err := editableSettings(config.SettingsConfig{Editable: test.editable})
+			if test.wantErr {
+				if !errors.Is(err, errUnknownSetting) {
+					t.Fatalf("editableSettings(%q) error = %v, want %v", test.editable, err, errUnknownSetting)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("editableSettings(%q) error = %v", test.editable, err)
+			}
+
+			if len(editable) != len(test.editable) {
+				t.Errorf("editableSettings(%q) = %v", test.editable, editable)
+			}
+
+			for _, key := range test.editable {
+				if _, ok := editable[key]; !ok {
+					t.Errorf("editableSettings(%q) is missing %s", test.editable, key)
+				}
+			}
+		})
+	}
+}
+
+func TestSettingParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key    string
+		text   string
+		want   any
+		wantOK bool
+	}{
+		{key: "peer-port", text: "51413", want: 51413, wantOK: true},
+		{key: "peer-port", text: "65535", want: 65535, wantOK: true},
+		{key: "peer-port", text: "0"},
+		{key: "peer-port", text: "70000"},
+		{key: "peer-limit-global", text: "0", want: 0, wantOK: true},
+		{key: "peer-limit-global", text: "65536"},
+		{key: "download-queue-size", text: "100000", want: 100000, wantOK: true},
+		{key: "download-queue-size", text: "-1"},
+		{key: "download-queue-size", text: "five"},
+		{key: "seedRatioLimit", text: "1.5", want: 1.5, wantOK: true},
+		{key: "seedRatioLimit", text: "+Inf"},
+		{key: "download-dir", text: "/downloads/done/", want: "/downloads/done", wantOK: true},
+		{key: "download-dir", text: "downloads"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key+" "+test.text, func(t *testing.T) {
+			t.Parallel()
+
+			item, ok := findSetting(test.key)
+			if !ok {
+				t.Fatalf("findSetting(%s) found nothing", test.key)
+			}
+
+			got, gotOK := item.parse(test.text)
+			if gotOK != test.wantOK || (gotOK && got != test.want) {
+				t.Errorf("parse(%q) = %v, %v, want %v, %v", test.text, got, gotOK, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestSettingHint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "peer-port", want: "a whole number from 1 to 65535"},
+		{key: "idle-seeding-limit", want: "a whole number from 0 to 65535"},
+		{key: "download-queue-size", want: "a whole number, like 50"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			t.Parallel()
+
+			item, _ := findSetting(test.key)
+			if got := item.hint(); got != test.want {
+				t.Errorf("hint() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// unwindTimeout is how long handlers get to return after their context is cancelled.
+const unwindTimeout = 2 * time.Second
+
+const msgRetryAfterRestart = "The bot is restarting and couldn't finish this request. Please send it again in a minute."
+
+// inflightTracker keeps track of updates whose handlers are still running.
+type inflightTracker struct {
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	nextID uint64
+	active map[uint64]incomingUpdate
+	// abandoned are the messages whose users were asked to send them again;
+	// the cancelled handlers must not reply to them any more.
+	abandoned map[messageRef]struct{}
+}
+
+// messageRef identifies a message across chats.
+type messageRef struct {
+	chatID    int64
+	messageID int
+}
+
+func newInflightTracker() *inflightTracker {
+	return &inflightTracker{
+		active:    make(map[uint64]incomingUpdate),
+		abandoned: make(map[messageRef]struct{}),
+	}
+}
+
+// track registers a handler for update and returns the function that marks it finished.
+func (t *inflightTracker) track(update incomingUpdate) func() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nextID++
+	handlerID := t.nextID
+	t.active[handlerID] = update
+	t.wg.Add(1)
+
+	return func() {
+		t.mu.Lock()
+		delete(t.active, handlerID)
+		t.mu.Unlock()
+
+		t.wg.Done()
+	}
+}
+
+// wait blocks until all tracked handlers finish or timeout elapses, reporting whether they finished.
+func (t *inflightTracker) wait(timeout time.Duration) bool {
+	finished := make(chan struct{})
+
+	go func() {
+		t.wg.Wait()
+		close(finished)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-finished:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// abandon marks msg as answered with msgRetryAfterRestart.
+func (t *inflightTracker) abandon(msg *tgbotapi.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.abandoned[messageRef{chatID: msg.Chat.ID, messageID: msg.MessageID}] = struct{}{}
+}
+
+// wasAbandoned reports whether the user was asked to send msg again.
+func (t *inflightTracker) wasAbandoned(msg *tgbotapi.Message) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.abandoned[messageRef{chatID: msg.Chat.ID, messageID: msg.MessageID}]
+
+	return ok
+}
+
+func (t *inflightTracker) pending() []incomingUpdate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	updates := make([]incomingUpdate, 0, len(t.active))
+	for _, update := range t.active {
+		updates = append(updates, update)
+	}
+
+	return updates
+}
+
+// drain waits up to the grace period for running handlers. Handlers still
+// running afterwards are cancelled, logged, and their users asked to retry;
+// that notice is the last reply to their messages, so the errors of the
+// cancelled calls aren't reported too.
+func (b *Bot) drain(cancelHandlers context.CancelFunc) {
+	b.logger.Info("waiting for in-flight handlers", "grace_period", b.gracePeriod)
+
+	if b.inflight.wait(b.gracePeriod) {
+		b.logger.Info("all handlers finished")
+
+		return
+	}
+
+	unfinished := b.inflight.pending()
+
+	for _, update := range unfinished {
+		msg := update.Message
+		if msg == nil && update.CallbackQuery != nil {
+			msg = update.CallbackQuery.Message
+		}
+
+		if msg == nil {
+			continue
+		}
+
+		b.logger.Warn("handler did not finish before shutdown",
+			"update_id", update.UpdateID,
+			"chat_id", msg.Chat.ID,
+			"type", updateType(update),
+		)
+
+		b.reply(msg, msgRetryAfterRestart)
+		b.inflight.abandon(msg)
+	}
+
+	cancelHandlers()
+
+	if !b.inflight.wait(unwindTimeout) {
+		b.logger.Warn("handlers still running after cancellation", "count", len(b.inflight.pending()))
+	}
+}
+
+// backgroundTasks tracks the goroutines that call Transmission outside update
+// handlers, such as watchers and progress cards, so that shutdown can wait for
+// them before closing the clients.
+type backgroundTasks struct {
+	wg sync.WaitGroup
+	mu sync.Mutex
+	// stop is closed when shutdown begins; no task starts afterwards.
+	stop    chan struct{}
+	stopped bool
+}
+
+func newBackgroundTasks() *backgroundTasks {
+	return &backgroundTasks{stop: make(chan struct{})}
+}
+
+// start runs task on its own goroutine with a context that is also cancelled
+// when shutdown begins.
+func (t *backgroundTasks) start(ctx context.Context, task func(context.Context)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopped {
+		return
+	}
+
+	t.wg.Go(func() {
+		taskCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		go func() {
+			select {
+			case <-t.stop:
+				cancel()
+			case <-taskCtx.Done():
+			}
+		}()
+
+		task(taskCtx)
+	})
+}
+
+// stopAndWait cancels the running tasks and waits up to timeout for them to
+// return, reporting whether they did.
+func (t *backgroundTasks) stopAndWait(timeout time.Duration) bool {
+	t.mu.Lock()
+	if !t.stopped {
+		t.stopped = true
+		close(t.stop)
+	}
+	t.mu.Unlock()
+
+	finished := make(chan struct{})
+
+	go func() {
+		t.wg.Wait()
+		close(finished)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-finished:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// stopBackground stops the background tasks, waiting up to the grace period.
+func (b *Bot) stopBackground() {
+	if !b.background.stopAndWait(b.gracePeriod) {
+		b.logger.Warn("background tasks still running at shutdown", "grace_period", b.gracePeriod)
+	}
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestBackgroundTasksStopAndWait(t *testing.T) {
+	t.Parallel()
+
+	tasks := newBackgroundTasks()
+	cancelled := make(chan struct{})
+
+	tasks.start(context.Background(), func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	})
+
+	if !tasks.stopAndWait(time.Second) {
+		t.Fatal("stopAndWait() = false, want the task to return once cancelled")
+	}
+
+	select {
+	case <-cancelled:
+	default:
+		t.Fatal("task context was not cancelled")
+	}
+
+	started := false
+
+	tasks.start(context.Background(), func(context.Context) { started = true })
+
+	if !tasks.stopAndWait(time.Second) || started {
+		t.Error("a task started after stopAndWait ran")
+	}
+}
+
+func TestBackgroundTasksTimeout(t *testing.T) {
+	t.Parallel()
+
+	tasks := newBackgroundTasks()
+	release := make(chan struct{})
+
+	tasks.start(context.Background(), func(context.Context) { <-release })
+
+	if tasks.stopAndWait(10 * time.Millisecond) {
+		t.Error("stopAndWait() = true, want false for a task ignoring cancellation")
+	}
+
+	close(release)
+}
+
+// TestDrainRepliesOnlyWithRetryNotice checks that a handler cancelled at
+// shutdown doesn't follow the retry notice with an error of its own.
+func TestDrainRepliesOnlyWithRetryNotice(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent = append(sent, r.FormValue("text"))
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":2,"chat":{"id":42,"type":"private"}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	api := &tgbotapi.BotAPI{Token: "token", Client: server.Client(), Buffer: 1}
+	api.SetAPIEndpoint(server.URL + "/bot%s/%s")
+
+	logger := slog.New(slog.DiscardHandler)
+	bot := &Bot{
+		api:         api,
+		sender:      newSender(1000, 6000, logger),
+		inflight:    newInflightTracker(),
+		threads:     newThreadIndex(),
+		gracePeriod: 10 * time.Millisecond,
+		logger:      logger,
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	t.Cleanup(cancel)
+
+	go bot.sender.run(ctx)
+
+	msg := &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 42, Type: "private"}}
+	done := bot.inflight.track(incomingUpdate{Update: tgbotapi.Update{UpdateID: 1, Message: msg}})
+	handlerCtx, cancelHandler := context.WithCancel(ctx)
+
+	go func() {
+		defer done()
+
+		<-handlerCtx.Done()
+		bot.reply(msg, "Failed to list torrents. Please try again later.")
+	}()
+
+	bot.drain(cancelHandler)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !slices.Equal(sent, []string{msgRetryAfterRestart}) {
+		t.Errorf("sent %q, want only the retry notice", sent)
+	}
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+const bytesUnit = 1024
+
+// handleStats shows transfer statistics for the instance named by an
+// argument, or for every instance followed by totals.
+func (b *Bot) handleStats(ctx context.Context, msg *tgbotapi.Message) {
+	clients := b.listInstances(msg)
+
+	var (
+		text  strings.Builder
+		total transmission.Stats
+	)
+
+	reported := 0
+
+	for _, client := range clients {
+		stats, err := client.SessionStats(ctx)
+		if err != nil {
+			b.logger.Error("failed to get session stats", "error", err, "instance", client.Name())
+			text.WriteString(b.instanceFailure(client, "Failed to get statistics", err) + "\n\n")
+
+			continue
+		}
+
+		if b.multiInstance() {
+			text.WriteString(client.Name() + ":\n")
+		}
+
+		writeStats(&text, stats)
+		text.WriteString("\n")
+
+		total.TorrentCount += stats.TorrentCount
+		total.ActiveTorrentCount += stats.ActiveTorrentCount
+		total.PausedTorrentCount += stats.PausedTorrentCount
+		total.DownloadSpeed += stats.DownloadSpeed
+		total.UploadSpeed += stats.UploadSpeed
+		total.DownloadedBytes += stats.DownloadedBytes
+		total.UploadedBytes += stats.UploadedBytes
+		reported++
+	}
+
+	if reported > 1 {
+		text.WriteString("Total:\n")
+		writeStats(&text, &total)
+	}
+
+	b.reply(msg, strings.TrimSpace(text.String()))
+}
+
+func writeStats(text *strings.Builder, stats *transmission.Stats) {
+	fmt.Fprintf(text, "Torrents: %d (%d active, %d paused)\n",
+		stats.TorrentCount, stats.ActiveTorrentCount, stats.PausedTorrentCount)
+	fmt.Fprintf(text, "Speed: ↓ %s/s ↑ %s/s\n", formatBytes(stats.DownloadSpeed), formatBytes(stats.UploadSpeed))
+	fmt.Fprintf(text, "All time: ↓ %s ↑ %s\n", formatBytes(stats.DownloadedBytes), formatBytes(stats.UploadedBytes))
+}
+
+// formatBytes renders a byte count with a binary unit, as in 1.5 GiB.
+func formatBytes(size int64) string {
+	if size < bytesUnit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size)
+	units := []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
+
+	unit := -1
+	for value >= bytesUnit && unit < len(units)-1 {
+		value /= bytesUnit
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+// stateMessages is what admins are told when a Transmission instance enters a
+// state; %s is the instance.
+//
+//nolint:gochecknoglobals // static lookup table
+var stateMessages = map[transmission.State]string{
+	transmission.StateUp:       "%s is back up.",
+	transmission.StateDegraded: "%s is having trouble: some requests are failing.",
+	transmission.StateDown:     "%s is down: requests keep failing. Users are told to try again later.",
+}
+
+// stateNotifyDelay is how long an instance must stay in a new state before
+// admins are told, so that a brief flap does not send any messages.
+const stateNotifyDelay = time.Minute
+
+// stateAlerts decides which Transmission state changes are worth telling admins about.
+type stateAlerts struct {
+	mu       sync.Mutex
+	current  map[string]stateChange
+	notified map[string]transmission.State
+}
+
+// stateChange is the state an instance entered and when.
+type stateChange struct {
+	state transmission.State
+	since time.Time
+}
+
+func newStateAlerts() *stateAlerts {
+	return &stateAlerts{
+		current:  make(map[string]stateChange),
+		notified: make(map[string]transmission.State),
+	}
+}
+
+// changed records that instance entered state at now.
+func (a *stateAlerts) changed(instance string, state transmission.State, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.current[instance] = stateChange{state: state, since: now}
+}
+
+// due returns the state admins should be told about for instance: one that
+// has held for stateNotifyDelay and differs from the last one reported. The
+// instance counts as up until told otherwise.
+func (a *stateAlerts) due(instance string, now time.Time) (transmission.State, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	change, ok := a.current[instance]
+	if !ok || now.Sub(change.since) < stateNotifyDelay || change.state == a.notified[instance] {
+		return 0, false
+	}
+
+	a.notified[instance] = change.state
+
+	return change.state, true
+}
+
+// onTransmissionState logs a state change of an instance and tells every admin
+// about it once the new state has held for stateNotifyDelay.
+func (b *Bot) onTransmissionState(instance string, from, to transmission.State) {
+	b.logger.Warn("transmission state changed", "instance", instance, "from", from.String(), "to", to.String())
+
+	b.stateAlerts.changed(instance, to, time.Now())
+
+	// Wait in the background: the change is reported from inside an RPC call,
+	// which should not wait for Telegram.
+	b.background.start(context.Background(), func(ctx context.Context) {
+		sleepContext(ctx, stateNotifyDelay)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		state, ok := b.stateAlerts.due(instance, time.Now())
+		if !ok {
+			return
+		}
+
+		subject := "Transmission"
+		if b.multiInstance() {
+			subject = fmt.Sprintf("Transmission %q", instance)
+		}
+
+		b.notifyAdmins(fmt.Sprintf(stateMessages[state], subject))
+	})
+}
+
+// notifyAdmins sends text to every admin in a private chat.
+func (b *Bot) notifyAdmins(text string) {
+	for _, adminID := range b.admins() {
+		_, err := b.send(tgbotapi.NewMessage(adminID, text), 0)
+		if err != nil {
+			b.logger.Error("failed to notify admin", "error", err, "user_id", adminID)
+		}
+	}
+}
+
+// admins returns the IDs of all admins, from the config file and runtime grants.
+func (b *Bot) admins() []int64 {
+	var admins []int64
+
+	for userID, role := range b.roles {
+		if role == config.RoleAdmin {
+			admins = append(admins, userID)
+		}
+	}
+
+	for _, grant := range b.store.Grants() {
+		if _, fromConfig := b.roles[grant.UserID]; !fromConfig && grant.Role == config.RoleAdmin {
+			admins = append(admins, grant.UserID)
+		}
+	}
+
+	return admins
+}
+
+// watchTransmission pings every Transmission instance periodically so that
+// outages and recoveries are noticed even while nobody is using the bot.
+func (b *Bot) watchTransmission(ctx context.Context) {
+	ticker := time.NewTicker(b.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, client := range b.instances {
+				err := client.Ping(ctx)
+				if err != nil {
+					b.logger.Debug("transmission ping failed", "error", err, "instance", client.Name())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lexfrei/transmission-bot/internal/transmission"
+)
+
+func TestStateAlertsDue(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	alerts := newStateAlerts()
+
+	if _, ok := alerts.due("nas", start); ok {
+		t.Error("due() reported an instance without changes")
+	}
+
+	// A flap that recovers within the delay is never reported.
+	alerts.changed("nas", transmission.StateDegraded, start)
+	alerts.changed("nas", transmission.StateUp, start.Add(10*time.Second))
+
+	if _, ok := alerts.due("nas", start.Add(stateNotifyDelay)); ok {
+		t.Error("due() reported a state that had not held yet")
+	}
+
+	if _, ok := alerts.due("nas", start.Add(2*stateNotifyDelay)); ok {
+		t.Error("due() reported up, which admins were never told was lost")
+	}
+
+	// A lasting outage is reported once, then its recovery.
+	alerts.changed("nas", transmission.StateDown, start.Add(3*stateNotifyDelay))
+
+	state, ok := alerts.due("nas", start.Add(4*stateNotifyDelay))
+	if !ok || state != transmission.StateDown {
+		t.Errorf("due() = %v, %v; want down, true", state, ok)
+	}
+
+	if _, ok := alerts.due("nas", start.Add(5*stateNotifyDelay)); ok {
+		t.Error("due() reported the same state twice")
+	}
+
+	alerts.changed("nas", transmission.StateUp, start.Add(5*stateNotifyDelay))
+
+	state, ok = alerts.due("nas", start.Add(6*stateNotifyDelay))
+	if !ok || state != transmission.StateUp {
+		t.Errorf("due() = %v, %v; want up, true", state, ok)
+	}
+}
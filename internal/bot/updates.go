@@ -0,0 +1,185 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	pollTimeoutSeconds = 60
+	pollRetryDelay     = 3 * time.Second
+)
+
+// incomingUpdate is a Telegram update together with the forum topic it was
+// posted in. tgbotapi v5.5.1 predates forum topics, so the thread ID is
+// decoded from the raw update separately.
+type incomingUpdate struct {
+	tgbotapi.Update
+
+	threadID int
+}
+
+// topicFields holds the message fields tgbotapi does not decode.
+type topicFields struct {
+	MessageThreadID int  `json:"message_thread_id"`
+	IsTopicMessage  bool `json:"is_topic_message"`
+}
+
+type rawTopicUpdate struct {
+	Message       *topicFields `json:"message"`
+	CallbackQuery *struct {
+		Message *topicFields `json:"message"`
+	} `json:"callback_query"`
+}
+
+// updateBatch is a getUpdates result decoded one update at a time.
+type updateBatch struct {
+	updates []incomingUpdate
+	// skipped are the errors of the updates that couldn't be decoded.
+	skipped []error
+	// offset confirms every update of the batch, including the skipped ones.
+	offset int
+}
+
+// decodeUpdates decodes a getUpdates result. An update that can't be decoded
+// is skipped rather than failing the batch, so it doesn't come back on every
+// poll and hold up the ones after it.
+func decodeUpdates(raw []byte, offset int) (updateBatch, error) {
+	var items []json.RawMessage
+
+	err := json.Unmarshal(raw, &items)
+	if err != nil {
+		return updateBatch{}, fmt.Errorf("decoding updates: %w", err)
+	}
+
+	batch := updateBatch{updates: make([]incomingUpdate, 0, len(items)), offset: offset}
+
+	for _, item := range items {
+		var header struct {
+			UpdateID int `json:"update_id"`
+		}
+
+		// The ID of an update that doesn't decode may still be readable.
+		_ = json.Unmarshal(item, &header)
+
+		if header.UpdateID >= batch.offset {
+			batch.offset = header.UpdateID + 1
+		}
+
+		update, decodeErr := decodeUpdate(item)
+		if decodeErr != nil {
+			batch.skipped = append(batch.skipped, fmt.Errorf("update %d: %w", header.UpdateID, decodeErr))
+
+			continue
+		}
+
+		batch.updates = append(batch.updates, update)
+	}
+
+	return batch, nil
+}
+
+// decodeUpdate decodes a single update as delivered by a webhook.
+func decodeUpdate(raw []byte) (incomingUpdate, error) {
+	var update tgbotapi.Update
+
+	err := json.Unmarshal(raw, &update)
+	if err != nil {
+		return incomingUpdate{}, fmt.Errorf("decoding update: %w", err)
+	}
+
+	var topic rawTopicUpdate
+
+	err = json.Unmarshal(raw, &topic)
+	if err != nil {
+		return incomingUpdate{}, fmt.Errorf("decoding update topic: %w", err)
+	}
+
+	return withTopic(update, topic), nil
+}
+
+func withTopic(update tgbotapi.Update, topic rawTopicUpdate) incomingUpdate {
+	fields := topic.Message
+	if fields == nil && topic.CallbackQuery != nil {
+		fields = topic.CallbackQuery.Message
+	}
+
+	incoming := incomingUpdate{Update: update}
+	if fields != nil && fields.IsTopicMessage {
+		incoming.threadID = fields.MessageThreadID
+	}
+
+	return incoming
+}
+
+// receiveUpdates feeds updates from the configured source, webhook or long
+// polling, to out until ctx is cancelled. Both sources share the same dispatch.
+func (b *Bot) receiveUpdates(ctx context.Context, out chan<- incomingUpdate) error {
+	if b.webhook.URL != "" {
+		return b.serveWebhook(ctx, out)
+	}
+
+	// A webhook left over from a previous run makes getUpdates fail.
+	b.deleteWebhook()
+
+	b.pollUpdates(ctx, out)
+
+	return nil
+}
+
+// pollUpdates long-polls getUpdates and feeds decoded updates to out until ctx is cancelled.
+func (b *Bot) pollUpdates(ctx context.Context, out chan<- incomingUpdate) {
+	offset := 0
+
+	for ctx.Err() == nil {
+		params := tgbotapi.Params{
+			"timeout": strconv.Itoa(pollTimeoutSeconds),
+		}
+		params.AddNonZero("offset", offset)
+
+		resp, err := b.api.MakeRequest("getUpdates", params)
+		if err != nil {
+			b.logger.Error("failed to get updates, retrying", "error", err, "delay", pollRetryDelay)
+			sleepContext(ctx, pollRetryDelay)
+
+			continue
+		}
+
+		batch, err := decodeUpdates(resp.Result, offset)
+		if err != nil {
+			b.logger.Error("failed to decode updates", "error", err)
+			sleepContext(ctx, pollRetryDelay)
+
+			continue
+		}
+
+		for _, skipErr := range batch.skipped {
+			b.logger.Error("skipping an update that can't be decoded", "error", skipErr)
+		}
+
+		offset = batch.offset
+
+		for _, update := range batch.updates {
+			select {
+			case out <- update:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
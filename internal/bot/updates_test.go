@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// badUpdate has a message Telegram would never send: a string message ID.
+const badUpdate = `{"update_id":8,"message":{"message_id":"one","chat":{"id":42,"type":"private"}}}`
+
+func TestDecodeUpdates(t *testing.T) {
+	t.Parallel()
+
+	goodUpdate := func(updateID int) string {
+		return fmt.Sprintf(`{"update_id":%d,"message":{"message_id":1,"chat":{"id":42,"type":"private"},"text":"hi"}}`,
+			updateID)
+	}
+
+	tests := []struct {
+		name        string
+		raw         string
+		offset      int
+		wantIDs     []int
+		wantSkipped int
+		wantOffset  int
+		wantErr     bool
+	}{
+		{name: "empty", raw: `[]`, offset: 5, wantOffset: 5},
+		{name: "good", raw: "[" + goodUpdate(7) + "," + goodUpdate(9) + "]", wantIDs: []int{7, 9}, wantOffset: 10},
+		{name: "topic", raw: "[" + testUpdate + "]", wantIDs: []int{7}, wantOffset: 8},
+		{name: "malformed in the middle", raw: "[" + goodUpdate(7) + "," + badUpdate + "," + goodUpdate(9) + "]",
+			wantIDs: []int{7, 9}, wantSkipped: 1, wantOffset: 10},
+		{name: "malformed last", raw: "[" + goodUpdate(7) + "," + badUpdate + "]",
+			wantIDs: []int{7}, wantSkipped: 1, wantOffset: 9},
+		{name: "malformed alone", raw: "[" + badUpdate + "]", wantSkipped: 1, wantOffset: 9},
+		{name: "not a list", raw: `{"update_id":1}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			batch, err := decodeUpdates([]byte(test.raw), test.offset)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("decodeUpdates() error = %v, want error %v", err, test.wantErr)
+			}
+
+			if test.wantErr {
+				return
+			}
+
+			if len(batch.updates) != len(test.wantIDs) {
+				t.Fatalf("decodeUpdates() decoded %d updates, want %d", len(batch.updates), len(test.wantIDs))
+			}
+
+			for i, update := range batch.updates {
+				if update.UpdateID != test.wantIDs[i] {
+					t.Errorf("update %d has ID %d, want %d", i, update.UpdateID, test.wantIDs[i])
+				}
+			}
+
+			if len(batch.skipped) != test.wantSkipped || batch.offset != test.wantOffset {
+				t.Errorf("decodeUpdates() skipped %v with offset %d, want %d skipped with offset %d",
+					batch.skipped, batch.offset, test.wantSkipped, test.wantOffset)
+			}
+		})
+	}
+}
+
+// TestPollUpdatesSkipsMalformedUpdate checks that an update the bot can't
+// decode is confirmed with the rest of its batch instead of being polled again.
+func TestPollUpdatesSkipsMalformedUpdate(t *testing.T) {
+	t.Parallel()
+
+	offsets := make(chan string, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.FormValue("offset") == "" {
+			_, _ = fmt.Fprintf(w, `{"ok":true,"result":[%s,%s]}`, badUpdate, testUpdate)
+
+			return
+		}
+
+		select {
+		case offsets <- r.FormValue("offset"):
+		default:
+		}
+
+		_, _ = w.Write([]byte(`{"ok":true,"result":[]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	api := &tgbotapi.BotAPI{Token: "token", Client: server.Client(), Buffer: 1}
+	api.SetAPIEndpoint(server.URL + "/bot%s/%s")
+
+	bot := &Bot{api: api, logger: slog.New(slog.DiscardHandler)}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	t.Cleanup(cancel)
+
+	out := make(chan incomingUpdate, 1)
+	go bot.pollUpdates(ctx, out)
+
+	select {
+	case update := <-out:
+		if update.UpdateID != 7 {
+			t.Errorf("got update %d, want 7", update.UpdateID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the update after the malformed one was not delivered")
+	}
+
+	select {
+	case offset := <-offsets:
+		if offset != "9" {
+			t.Errorf("next poll used offset %s, want 9", offset)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("polling stopped after the malformed update")
+	}
+}
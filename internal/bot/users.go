@@ -0,0 +1,208 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+	"github.com/lexfrei/transmission-bot/internal/store"
+)
+
+// inviteCodeBytes yields a 22-character code, well within Telegram's 64-character start payload limit.
+const inviteCodeBytes = 16
+
+func (b *Bot) handleAllow(msg *tgbotapi.Message) {
+	args := strings.Fields(msg.CommandArguments())
+	if len(args) == 0 || len(args) > 2 {
+		b.reply(msg, "Usage: /allow <user_id> [admin|user|viewer]\n\nThe role defaults to user.")
+
+		return
+	}
+
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || userID == 0 {
+		b.reply(msg, "Invalid user ID. Please provide a numeric ID.")
+
+		return
+	}
+
+	role := config.RoleUser
+	if len(args) == 2 {
+		role = config.Role(strings.ToLower(args[1]))
+	}
+
+	if !role.Valid() {
+		b.reply(msg, "Invalid role. Use admin, user, or viewer.")
+
+		return
+	}
+
+	if _, static := b.roles[userID]; static {
+		b.reply(msg, "This user is configured in the config file; change their role there.")
+
+		return
+	}
+
+	saveErr := b.store.SaveGrant(store.Grant{
+		UserID:    userID,
+		Role:      role,
+		GrantedBy: msg.From.ID,
+		GrantedAt: time.Now(),
+	})
+	if saveErr != nil {
+		b.logger.Error("failed to save grant", "error", saveErr, "user_id", userID)
+		b.reply(msg, "Failed to save access, please try again.")
+
+		return
+	}
+
+	b.registerUserCommands(userID, role)
+
+	b.logger.Info("user allowed",
+		"user_id", userID,
+		"role", role,
+		"granted_by", msg.From.ID,
+	)
+
+	b.reply(msg, fmt.Sprintf("User %d now has the %s role.", userID, role))
+}
+
+func (b *Bot) handleDeny(msg *tgbotapi.Message) {
+	args := strings.Fields(msg.CommandArguments())
+	if len(args) != 1 {
+		b.reply(msg, "Usage: /deny <user_id>")
+
+		return
+	}
+
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.reply(msg, "Invalid user ID. Please provide a numeric ID.")
+
+		return
+	}
+
+	if _, static := b.roles[userID]; static {
+		b.reply(msg, "This user is configured in the config file and can't be removed at runtime.")
+
+		return
+	}
+
+	revoked, revokeErr := b.store.RevokeGrant(userID)
+	if revokeErr != nil {
+		b.logger.Error("failed to revoke grant", "error", revokeErr, "user_id", userID)
+		b.reply(msg, "Failed to revoke access, please try again.")
+
+		return
+	}
+
+	if !revoked {
+		b.reply(msg, fmt.Sprintf("User %d has no access to revoke.", userID))
+
+		return
+	}
+
+	b.unregisterUserCommands(userID)
+
+	b.logger.Info("user denied", "user_id", userID, "denied_by", msg.From.ID)
+
+	b.reply(msg, fmt.Sprintf("Access revoked for user %d.", userID))
+}
+
+func (b *Bot) handleInvite(msg *tgbotapi.Message) {
+	role := config.RoleUser
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		role = config.Role(strings.ToLower(arg))
+	}
+
+	if !role.Valid() {
+		b.reply(msg, "Usage: /invite [admin|user|viewer]\n\nThe role defaults to user.")
+
+		return
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		b.logger.Error("failed to generate invite code", "error", err)
+		b.reply(msg, "Failed to create invite, please try again.")
+
+		return
+	}
+
+	expiresAt := time.Now().Add(b.inviteTTL)
+
+	createErr := b.store.CreateInvite(store.Invite{
+		Code:      code,
+		Role:      role,
+		CreatedBy: msg.From.ID,
+		ExpiresAt: expiresAt,
+	})
+	if createErr != nil {
+		b.logger.Error("failed to save invite", "error", createErr)
+		b.reply(msg, "Failed to create invite, please try again.")
+
+		return
+	}
+
+	b.logger.Info("invite created", "role", role, "created_by", msg.From.ID, "expires_at", expiresAt)
+
+	b.reply(msg, fmt.Sprintf(
+		"Single-use invite for the %s role, valid until %s:\n\nhttps://t.me/%s?start=%s",
+		role,
+		expiresAt.UTC().Format(time.RFC1123),
+		b.api.Self.UserName,
+		code,
+	))
+}
+
+// redeemInvite handles /start <code> from a user who has no role yet.
+func (b *Bot) redeemInvite(msg *tgbotapi.Message, code string) {
+	grant, err := b.store.RedeemInvite(code, msg.From.ID, time.Now())
+
+	switch {
+	case errors.Is(err, store.ErrInviteNotFound), errors.Is(err, store.ErrInviteExpired):
+		b.logger.Warn("invalid invite",
+			"user_id", msg.From.ID,
+			"username", msg.From.UserName,
+			"error", err,
+		)
+		b.reply(msg, "This invite link is invalid or has expired. Ask an admin for a new one.")
+
+		return
+	case err != nil:
+		b.logger.Error("failed to redeem invite", "error", err, "user_id", msg.From.ID)
+		b.reply(msg, "Failed to accept the invite, please try again.")
+
+		return
+	}
+
+	b.rememberUser(msg)
+	b.registerUserCommands(msg.From.ID, grant.Role)
+
+	b.logger.Info("invite redeemed",
+		"user_id", msg.From.ID,
+		"username", msg.From.UserName,
+		"role", grant.Role,
+		"invited_by", grant.GrantedBy,
+	)
+
+	b.handleStart(msg)
+}
+
+func newInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeBytes)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,187 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// secretTokenHeader carries the secret_token passed to setWebhook.
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+	// maxUpdateSize caps the size of a single webhook request body.
+	maxUpdateSize = 1 << 20
+
+	webhookReadHeaderTimeout = 10 * time.Second
+	webhookShutdownTimeout   = 10 * time.Second
+)
+
+// webhookHandler receives updates pushed by Telegram and forwards them to the shared dispatch loop.
+type webhookHandler struct {
+	secretToken string
+	updates     chan<- incomingUpdate
+	logger      *slog.Logger
+}
+
+func newWebhookHandler(secretToken string, updates chan<- incomingUpdate, logger *slog.Logger) *webhookHandler {
+	return &webhookHandler{
+		secretToken: secretToken,
+		updates:     updates,
+		logger:      logger,
+	}
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	if h.secretToken != "" {
+		got := r.Header.Get(secretTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(h.secretToken)) != 1 {
+			h.logger.Warn("webhook request with invalid secret token", "remote_addr", r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+
+			return
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUpdateSize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+
+		return
+	}
+
+	update, err := decodeUpdate(body)
+	if err != nil {
+		h.logger.Error("failed to decode webhook update", "error", err)
+		http.Error(w, "invalid update", http.StatusBadRequest)
+
+		return
+	}
+
+	select {
+	case h.updates <- update:
+		w.WriteHeader(http.StatusOK)
+	case <-r.Context().Done():
+	}
+}
+
+// serveWebhook registers the webhook with Telegram and serves it until ctx is
+// cancelled, then shuts the server down and removes the webhook.
+func (b *Bot) serveWebhook(ctx context.Context, updates chan<- incomingUpdate) error {
+	publicURL, err := url.Parse(b.webhook.URL)
+	if err != nil {
+		return fmt.Errorf("parsing webhook url: %w", err)
+	}
+
+	path := publicURL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, newWebhookHandler(b.webhook.SecretToken, updates, b.logger))
+
+	server := &http.Server{
+		Addr:              b.webhook.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: webhookReadHeaderTimeout,
+		// Requests still waiting for dispatch at shutdown are abandoned without
+		// a 200, so Telegram redelivers them instead of the update being lost.
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- b.listen(server)
+	}()
+
+	setErr := b.setWebhook()
+	if setErr != nil {
+		_ = server.Close()
+
+		return setErr
+	}
+
+	b.logger.Info("webhook started", "listen", b.webhook.Listen, "url", publicURL.Redacted())
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("serving webhook: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookShutdownTimeout)
+	defer cancel()
+
+	shutdownErr := server.Shutdown(shutdownCtx)
+	if shutdownErr != nil {
+		b.logger.Error("failed to shut down webhook server", "error", shutdownErr)
+	}
+
+	b.deleteWebhook()
+
+	return nil
+}
+
+func (b *Bot) listen(server *http.Server) error {
+	var err error
+
+	if b.webhook.CertFile != "" {
+		err = server.ListenAndServeTLS(b.webhook.CertFile, b.webhook.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return fmt.Errorf("listening on %s: %w", server.Addr, err)
+}
+
+// setWebhook registers the public URL with Telegram. tgbotapi v5.5.1 does not
+// know about secret_token, so the request is built by hand.
+func (b *Bot) setWebhook() error {
+	params := tgbotapi.Params{"url": b.webhook.URL}
+	params.AddNonEmpty("secret_token", b.webhook.SecretToken)
+
+	var err error
+
+	if b.webhook.CertFile != "" {
+		_, err = b.api.UploadFiles("setWebhook", params, []tgbotapi.RequestFile{
+			{Name: "certificate", Data: tgbotapi.FilePath(b.webhook.CertFile)},
+		})
+	} else {
+		_, err = b.api.MakeRequest("setWebhook", params)
+	}
+
+	if err != nil {
+		return fmt.Errorf("setting webhook: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bot) deleteWebhook() {
+	_, err := b.api.Request(tgbotapi.DeleteWebhookConfig{})
+	if err != nil {
+		b.logger.Error("failed to delete webhook", "error", err)
+	}
+}
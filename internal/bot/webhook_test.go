@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testUpdate = `{"update_id":7,"message":{"message_id":1,"chat":{"id":42,"type":"private"},` +
+	`"text":"hi","is_topic_message":true,"message_thread_id":3}}`
+
+func TestWebhookHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		method     string
+		secret     string
+		body       string
+		wantStatus int
+		wantUpdate bool
+	}{
+		{name: "good secret", method: http.MethodPost, secret: "s3cret", body: testUpdate,
+			wantStatus: http.StatusOK, wantUpdate: true},
+		{name: "bad secret", method: http.MethodPost, secret: "wrong", body: testUpdate,
+			wantStatus: http.StatusForbidden},
+		{name: "missing secret", method: http.MethodPost, body: testUpdate, wantStatus: http.StatusForbidden},
+		{name: "bad body", method: http.MethodPost, secret: "s3cret", body: `{"update_id":`,
+			wantStatus: http.StatusBadRequest},
+		{name: "oversized body", method: http.MethodPost, secret: "s3cret",
+			body: `{"update_id":1,"x":"` + strings.Repeat("a", maxUpdateSize) + `"}`, wantStatus: http.StatusBadRequest},
+		{name: "wrong method", method: http.MethodGet, secret: "s3cret", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			updates := make(chan incomingUpdate, 1)
+			handler := newWebhookHandler("s3cret", updates, slog.New(slog.DiscardHandler))
+
+			req := httptest.NewRequest(test.method, "/telegram", strings.NewReader(test.body))
+			if test.secret != "" {
+				req.Header.Set(secretTokenHeader, test.secret)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, test.wantStatus)
+			}
+
+			checkDelivered(t, updates, test.wantUpdate)
+		})
+	}
+}
+
+func checkDelivered(t *testing.T, updates chan incomingUpdate, want bool) {
+	t.Helper()
+
+	select {
+	case update := <-updates:
+		if !want {
+			t.Fatalf("unexpected update %d", update.UpdateID)
+		}
+
+		if update.UpdateID != 7 || update.Message == nil || update.Message.Text != "hi" || update.threadID != 3 {
+			t.Errorf("update = %+v, thread %d; want update 7 with text hi in thread 3", update.Update, update.threadID)
+		}
+	default:
+		if want {
+			t.Fatal("update was not delivered")
+		}
+	}
+}
+
+func TestWebhookHandlerWithoutSecret(t *testing.T) {
+	t.Parallel()
+
+	updates := make(chan incomingUpdate, 1)
+	handler := newWebhookHandler("", updates, slog.New(slog.DiscardHandler))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testUpdate)))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	checkDelivered(t, updates, true)
+}
@@ -0,0 +1,191 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// workerQueueSize is the number of updates each worker buffers; updates
+// beyond that are rejected.
+const workerQueueSize = 64
+
+const (
+	msgSlowDown = "You're sending requests too fast. Please wait a moment and try again."
+	msgBusy     = "I'm busy with earlier requests in this chat. Please try again in a minute."
+)
+
+// workerPool handles updates on a fixed number of goroutines. Updates from the
+// same chat always go to the same worker, so they are processed in order.
+type workerPool struct {
+	mu     sync.RWMutex
+	closed bool
+	queues []chan job
+}
+
+// job is a queued update and the function to call once it has been handled.
+type job struct {
+	update incomingUpdate
+	// run, when set, is work the bot queues itself, such as a complete album;
+	// it is done instead of handling update.
+	run  func(context.Context)
+	done func()
+}
+
+func newWorkerPool(ctx context.Context, workers int, handle func(context.Context, incomingUpdate)) *workerPool {
+	pool := &workerPool{queues: make([]chan job, workers)}
+
+	for i := range pool.queues {
+		queue := make(chan job, workerQueueSize)
+		pool.queues[i] = queue
+
+		go func() {
+			for queued := range queue {
+				if queued.run != nil {
+					queued.run(ctx)
+				} else {
+					handle(ctx, queued.update)
+				}
+
+				queued.done()
+			}
+		}()
+	}
+
+	return pool
+}
+
+// submit queues an update on its chat's worker and reports whether it was
+// queued. It never blocks, so one busy chat can't hold up updates for the others.
+func (p *workerPool) submit(update incomingUpdate, done func()) bool {
+	return p.enqueue(chatKey(update), job{update: update, done: done})
+}
+
+// submitFunc queues run on chatID's worker, in order with the chat's updates,
+// and reports whether it was queued. Like submit, it never blocks.
+func (p *workerPool) submitFunc(chatID int64, run func(context.Context), done func()) bool {
+	return p.enqueue(chatID, job{run: run, done: done})
+}
+
+// enqueue queues a job on the worker of key unless that worker is full or the
+// pool is stopped.
+func (p *workerPool) enqueue(key int64, queued job) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return false
+	}
+
+	if key < 0 {
+		key = -key
+	}
+
+	select {
+	case p.queues[key%int64(len(p.queues))] <- queued:
+		return true
+	default:
+		return false
+	}
+}
+
+// rejectBusy tells a user that their update was dropped because their chat's
+// worker is full. Only users with a role are told, and in groups only about
+// commands, since other group messages are mostly not for the bot.
+func (b *Bot) rejectBusy(update incomingUpdate) {
+	b.logger.Warn("worker queue full, dropping update", "update_id", update.UpdateID, "chat_id", chatKey(update))
+
+	switch {
+	case update.CallbackQuery != nil:
+		if _, known := b.roleOf(update.CallbackQuery.From.ID); known {
+			b.answerCallback(update.CallbackQuery, msgBusy)
+		}
+	case update.Message != nil && update.Message.From != nil:
+		msg := update.Message
+		if _, known := b.roleOf(msg.From.ID); known && (msg.Chat.IsPrivate() || msg.IsCommand()) {
+			b.reply(msg, msgBusy)
+		}
+	}
+}
+
+// stop closes the queues; workers exit after handling what was already
+// queued. Jobs submitted afterwards are refused.
+func (p *workerPool) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+
+	for _, queue := range p.queues {
+		close(queue)
+	}
+}
+
+// chatKey returns the chat an update belongs to, falling back to the sender.
+func chatKey(update incomingUpdate) int64 {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From.ID
+	default:
+		return 0
+	}
+}
+
+// userLimiter is a per-user token bucket. A zero rate disables limiting.
+type userLimiter struct {
+	mu         sync.Mutex
+	limit      rate.Limit
+	burst      int
+	warnPeriod time.Duration
+	users      map[int64]*userBucket
+}
+
+type userBucket struct {
+	limiter  *rate.Limiter
+	warnedAt time.Time
+}
+
+func newUserLimiter(perMinute float64, burst int) *userLimiter {
+	return &userLimiter{
+		limit:      rate.Limit(perMinute / time.Minute.Seconds()),
+		burst:      burst,
+		warnPeriod: time.Minute,
+		users:      make(map[int64]*userBucket),
+	}
+}
+
+// allow consumes a token for the user. When the request is rejected, warn
+// reports whether the user should be told, at most once per warnPeriod.
+func (l *userLimiter) allow(userID int64) (allowed, warn bool) {
+	if l.limit == 0 {
+		return true, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.users[userID]
+	if !ok {
+		bucket = &userBucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.users[userID] = bucket
+	}
+
+	if bucket.limiter.Allow() {
+		return true, false
+	}
+
+	now := time.Now()
+	if now.Sub(bucket.warnedAt) < l.warnPeriod {
+		return false, false
+	}
+
+	bucket.warnedAt = now
+
+	return false, true
+}
@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"context"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestWorkerPoolSubmitDoesNotBlock(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	pool := newWorkerPool(context.Background(), 1, func(context.Context, incomingUpdate) { <-release })
+
+	defer func() {
+		close(release)
+		pool.stop()
+	}()
+
+	update := incomingUpdate{Update: tgbotapi.Update{Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}}}}
+
+	// One update is taken by the worker, which then blocks; the rest fill the queue.
+	accepted := 0
+
+	for range workerQueueSize + 2 {
+		if pool.submit(update, func() {}) {
+			accepted++
+		}
+	}
+
+	if accepted < workerQueueSize || accepted > workerQueueSize+1 {
+		t.Errorf("accepted %d updates, want %d or %d", accepted, workerQueueSize, workerQueueSize+1)
+	}
+}
+
+func TestWorkerPoolSubmitFunc(t *testing.T) {
+	t.Parallel()
+
+	pool := newWorkerPool(context.Background(), 2, func(context.Context, incomingUpdate) {})
+
+	ran := make(chan struct{})
+	finished := make(chan struct{})
+
+	if !pool.submitFunc(-5, func(context.Context) { close(ran) }, func() { close(finished) }) {
+		t.Fatal("submitFunc() refused a job on an idle pool")
+	}
+
+	<-ran
+	<-finished
+
+	pool.stop()
+
+	if pool.submitFunc(-5, func(context.Context) {}, func() {}) {
+		t.Error("submitFunc() queued a job after stop")
+	}
+
+	if pool.submit(incomingUpdate{}, func() {}) {
+		t.Error("submit() queued an update after stop")
+	}
+}
@@ -4,7 +4,11 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/url"
+	"path"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -12,30 +16,371 @@ import (
 // Validation errors.
 var (
 	ErrMissingToken        = errors.New("telegram.token is required")
-	ErrMissingAllowedUsers = errors.New("telegram.allowed_users is required (at least one user ID)")
+	ErrMissingAllowedUsers = errors.New("telegram.allowed_users or telegram.users is required (at least one user ID)")
+	ErrInvalidUserID       = errors.New("telegram.users entries require a non-zero id")
+	ErrInvalidRole         = errors.New("invalid role (must be admin, user, or viewer)")
+	ErrInvalidInviteTTL    = errors.New("telegram.invite_ttl must be positive")
+	ErrInvalidWebhookURL   = errors.New("telegram.webhook.url must be an absolute https URL")
+	ErrMissingWebhookPair  = errors.New("telegram.webhook.cert_file and key_file must be set together")
+	ErrMissingListenAddr   = errors.New("telegram.webhook.listen is required in webhook mode")
+	ErrInvalidGracePeriod  = errors.New("shutdown.grace_period must not be negative")
+	ErrInvalidInstance     = errors.New("transmission.instances need a unique name of lowercase letters, digits, - or _")
+	ErrInvalidTimeout      = errors.New("transmission.timeouts must be positive")
+	ErrInvalidRetry        = errors.New("transmission.retry.attempts must be at least 1 and retry.backoff must not be negative")
+	ErrInvalidHealthCheck  = errors.New("transmission.health_interval must not be negative")
+	ErrInvalidWorkers      = errors.New("limits.workers must be at least 1")
+	ErrInvalidUserRate     = errors.New("limits.user_rate_per_minute must not be negative")
+	ErrInvalidUserBurst    = errors.New("limits.user_burst must be at least 1 when rate limiting is enabled")
+	ErrInvalidSendRate     = errors.New("limits.send_rate_per_second and limits.chat_sends_per_minute must be positive")
+	ErrInvalidFetch        = errors.New("fetch.max_size and fetch.timeout must be positive")
+	ErrInvalidUpload       = errors.New("upload.max_size and upload.timeout must be positive and upload.download_dirs absolute paths")
+	ErrInvalidImport       = errors.New("import.max_entries, import.max_size and import.timeout must be positive")
+	ErrInvalidProgress     = errors.New("progress.interval and progress.max_watch must be positive and progress.max_cards not negative")
+	ErrInvalidDashboard    = errors.New("dashboard.interval must be positive")
+	ErrInvalidNotify       = errors.New("notify.interval and notify.stall_after must be positive and notify.min_free_space not negative")
+	ErrInvalidBandwidth    = errors.New("bandwidth.windows need a name, days such as mon, distinct start and end times as HH:MM and limits not negative")
+	ErrInvalidTimezone     = errors.New("unknown timezone")
+	ErrInvalidTracker      = errors.New("magnet.trackers entries must be http, https, udp or wss URLs")
 	ErrMissingURL          = errors.New("transmission.url is required")
+	ErrMissingStoragePath  = errors.New("storage.path is required")
 )
 
+var instanceNameRegex = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
 // Config holds all configuration for the application.
 type Config struct {
 	Telegram     TelegramConfig     `mapstructure:"telegram"`
 	Transmission TransmissionConfig `mapstructure:"transmission"`
+	Storage      StorageConfig      `mapstructure:"storage"`
+	Health       HealthConfig       `mapstructure:"health"`
+	Shutdown     ShutdownConfig     `mapstructure:"shutdown"`
+	Limits       LimitsConfig       `mapstructure:"limits"`
+	Upload       UploadConfig       `mapstructure:"upload"`
+	Fetch        FetchConfig        `mapstructure:"fetch"`
+	Magnet       MagnetConfig       `mapstructure:"magnet"`
+	Import       ImportConfig       `mapstructure:"import"`
+	Progress     ProgressConfig     `mapstructure:"progress"`
+	Dashboard    DashboardConfig    `mapstructure:"dashboard"`
+	Notify       NotifyConfig       `mapstructure:"notify"`
+	Bandwidth    BandwidthConfig    `mapstructure:"bandwidth"`
+	Settings     SettingsConfig     `mapstructure:"settings"`
 	Log          LogConfig          `mapstructure:"log"`
 }
 
+// Role defines what a Telegram user is permitted to do.
+type Role string
+
+// Supported roles.
+const (
+	// RoleAdmin can do everything, including managing other users' torrents.
+	RoleAdmin Role = "admin"
+	// RoleUser can add torrents and manage only their own.
+	RoleUser Role = "user"
+	// RoleViewer can only list torrents.
+	RoleViewer Role = "viewer"
+)
+
+// Valid reports whether the role is one of the supported roles.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleUser, RoleViewer:
+		return true
+	default:
+		return false
+	}
+}
+
 // TelegramConfig holds Telegram bot configuration.
 type TelegramConfig struct {
-	Token        string  `mapstructure:"token"`
-	AllowedUsers []int64 `mapstructure:"allowed_users"`
+	Token string `mapstructure:"token"`
+	// AllowedUsers lists user IDs granted the admin role; kept for backward compatibility.
+	AllowedUsers []int64      `mapstructure:"allowed_users"`
+	Users        []UserConfig `mapstructure:"users"`
+	// AllowedChats lists group chat IDs the bot answers in; private chats are always allowed.
+	AllowedChats []int64 `mapstructure:"allowed_chats"`
+	// InviteTTL is how long an /invite code stays valid.
+	InviteTTL time.Duration `mapstructure:"invite_ttl"`
+	Webhook   WebhookConfig `mapstructure:"webhook"`
+}
+
+// WebhookConfig holds Telegram webhook configuration. Long polling is used when URL is empty.
+type WebhookConfig struct {
+	// URL is the public HTTPS address Telegram posts updates to; its path is also served locally.
+	URL string `mapstructure:"url"`
+	// Listen is the local address of the webhook HTTP server.
+	Listen string `mapstructure:"listen"`
+	// SecretToken is checked against the X-Telegram-Bot-Api-Secret-Token header of every request.
+	SecretToken string `mapstructure:"secret_token"`
+	// CertFile and KeyFile enable TLS on the local server; the certificate is also uploaded to Telegram.
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+}
+
+// UserConfig assigns a role to a Telegram user.
+type UserConfig struct {
+	ID   int64 `mapstructure:"id"`
+	Role Role  `mapstructure:"role"`
+}
+
+// Roles returns the role of every configured user. Entries in users take
+// precedence over allowed_users, whose members are treated as admins.
+func (c *TelegramConfig) Roles() map[int64]Role {
+	roles := make(map[int64]Role, len(c.AllowedUsers)+len(c.Users))
+
+	for _, userID := range c.AllowedUsers {
+		roles[userID] = RoleAdmin
+	}
+
+	for _, user := range c.Users {
+		roles[user.ID] = user.Role
+	}
+
+	return roles
 }
 
+// DefaultInstanceName names the single instance built from transmission.url when no instances are listed.
+const DefaultInstanceName = "default"
+
 // TransmissionConfig holds Transmission RPC configuration.
 type TransmissionConfig struct {
 	URL      string `mapstructure:"url"`
 	Username string `mapstructure:"username"`
 	Password string `mapstructure:"password"`
+	// Instances lists named Transmission servers; when empty, URL is the only one.
+	Instances []InstanceConfig `mapstructure:"instances"`
+	// Timeouts bound each RPC call by operation class.
+	Timeouts TimeoutsConfig `mapstructure:"timeouts"`
+	// Retry controls how idempotent calls are retried on transient errors.
+	Retry RetryConfig `mapstructure:"retry"`
+	// HealthInterval is how often Transmission is probed to track its state; 0 disables probing.
+	HealthInterval time.Duration `mapstructure:"health_interval"`
+}
+
+// InstanceConfig is a named Transmission server.
+type InstanceConfig struct {
+	// Name identifies the instance in commands (/use nas, /list nas) and torrent IDs (nas:12).
+	Name     string `mapstructure:"name"`
+	URL      string `mapstructure:"url"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// InstanceList returns the configured instances, or a single default instance
+// built from URL, Username and Password when none are listed.
+func (t *TransmissionConfig) InstanceList() []InstanceConfig {
+	if len(t.Instances) > 0 {
+		return t.Instances
+	}
+
+	return []InstanceConfig{{
+		Name:     DefaultInstanceName,
+		URL:      t.URL,
+		Username: t.Username,
+		Password: t.Password,
+	}}
+}
+
+// TimeoutsConfig holds RPC timeouts per operation class.
+type TimeoutsConfig struct {
+	// Read covers queries such as torrent-get and session-get.
+	Read time.Duration `mapstructure:"read"`
+	// Write covers changes such as torrent-remove.
+	Write time.Duration `mapstructure:"write"`
+	// Add covers torrent-add, which may upload a whole .torrent file.
+	Add time.Duration `mapstructure:"add"`
+}
+
+// RetryConfig holds the retry policy for idempotent RPC calls.
+type RetryConfig struct {
+	// Attempts is the total number of tries, including the first one.
+	Attempts int `mapstructure:"attempts"`
+	// Backoff is the delay before the first retry; it doubles on every retry.
+	Backoff time.Duration `mapstructure:"backoff"`
+}
+
+// StorageConfig holds persistent state configuration.
+type StorageConfig struct {
+	Path string `mapstructure:"path"`
+}
+
+// HealthConfig holds the health and metrics HTTP server configuration.
+type HealthConfig struct {
+	// Listen is the address serving /healthz, /readyz and /metrics; the server is disabled when empty.
+	Listen string `mapstructure:"listen"`
+}
+
+// ShutdownConfig holds graceful shutdown configuration.
+type ShutdownConfig struct {
+	// GracePeriod is how long in-flight handlers may run after a shutdown signal.
+	GracePeriod time.Duration `mapstructure:"grace_period"`
+}
+
+// LimitsConfig holds concurrency and rate limiting configuration.
+type LimitsConfig struct {
+	// Workers is the number of updates handled concurrently; updates from one chat are handled in order.
+	Workers int `mapstructure:"workers"`
+	// UserRatePerMinute is the sustained number of requests a user may make; 0 disables rate limiting.
+	UserRatePerMinute float64 `mapstructure:"user_rate_per_minute"`
+	// UserBurst is the number of requests a user may make at once before being limited.
+	UserBurst int `mapstructure:"user_burst"`
+	// SendRatePerSecond caps outgoing Telegram requests across all chats.
+	SendRatePerSecond float64 `mapstructure:"send_rate_per_second"`
+	// ChatSendsPerMinute caps outgoing messages to a single chat.
+	ChatSendsPerMinute float64 `mapstructure:"chat_sends_per_minute"`
+}
+
+// defaultUploadMaxSize is the default cap on files sent to the bot: 10 MiB.
+const defaultUploadMaxSize = 10 << 20
+
+// UploadConfig holds the limits for files sent to the bot.
+type UploadConfig struct {
+	// MaxSize is the largest file, in bytes, the bot downloads from Telegram.
+	MaxSize int64 `mapstructure:"max_size"`
+	// Timeout bounds the download of a file from Telegram.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// DownloadDirs are the directories, with everything below them, that the
+	// dir= caption option may choose. Empty turns dir= off.
+	DownloadDirs []string `mapstructure:"download_dirs"`
+}
+
+// defaultFetchMaxSize is the default cap on downloaded .torrent files: 10 MiB.
+const defaultFetchMaxSize = 10 << 20
+
+// FetchConfig holds the settings for downloading .torrent files from links.
+type FetchConfig struct {
+	// MaxSize is the largest .torrent file, in bytes, the bot downloads.
+	MaxSize int64 `mapstructure:"max_size"`
+	// Timeout bounds a whole download, including reading the body.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Cookies maps a domain to the Cookie header sent to it and its subdomains,
+	// as needed by private trackers.
+	Cookies map[string]string `mapstructure:"cookies"`
+}
+
+// MagnetConfig holds the settings for magnet links built by the bot.
+type MagnetConfig struct {
+	// Trackers are added to the magnet links made from bare info-hashes.
+	Trackers []string `mapstructure:"trackers"`
+}
+
+// defaultImportMaxSize is the default cap on the decompressed size of an archive: 50 MiB.
+const defaultImportMaxSize = 50 << 20
+
+// ImportConfig holds the limits for adding many torrents from one .txt or .zip file.
+type ImportConfig struct {
+	// MaxEntries is the most links or .torrent files taken from one file.
+	MaxEntries int `mapstructure:"max_entries"`
+	// MaxSize is the most bytes extracted from one archive.
+	MaxSize int64 `mapstructure:"max_size"`
+	// Timeout bounds the whole import; entries not reached in time are skipped.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// ProgressConfig holds the settings of the live progress cards posted for added torrents.
+type ProgressConfig struct {
+	// Interval is how often a card is refreshed.
+	Interval time.Duration `mapstructure:"interval"`
+	// MaxWatch is how long a card is refreshed before it stops with a summary.
+	MaxWatch time.Duration `mapstructure:"max_watch"`
+	// MaxCards caps the cards refreshed at once across all chats; 0 disables live cards.
+	MaxCards int `mapstructure:"max_cards"`
+}
+
+// DashboardConfig holds the settings of the pinned dashboards created with /dashboard.
+type DashboardConfig struct {
+	// Interval is how often dashboards are refreshed.
+	Interval time.Duration `mapstructure:"interval"`
 }
 
+// defaultNotifyMinFreeSpace is the free space below which disk alerts are sent: 5 GiB.
+const defaultNotifyMinFreeSpace = 5 << 30
+
+// NotifyConfig holds the settings of the watcher behind user notifications.
+type NotifyConfig struct {
+	// Interval is how often torrents and disk space are checked for events.
+	Interval time.Duration `mapstructure:"interval"`
+	// StallAfter is how long a download may receive nothing before it is reported as stalled.
+	StallAfter time.Duration `mapstructure:"stall_after"`
+	// MinFreeSpace is the free space, in bytes, below which a disk alert is sent; 0 disables them.
+	MinFreeSpace int64 `mapstructure:"min_free_space"`
+}
+
+// BandwidthConfig holds speed-limit windows that the bot applies to every
+// instance on its own schedule.
+type BandwidthConfig struct {
+	// Timezone is the IANA name of the zone the windows' times are in.
+	Timezone string `mapstructure:"timezone"`
+	// Default holds the limits outside every window.
+	Default SpeedLimitsConfig `mapstructure:"default"`
+	// Windows are checked in order; the first one covering the current time
+	// applies. Without windows the bot leaves the limits alone.
+	Windows []BandwidthWindow `mapstructure:"windows"`
+}
+
+// SpeedLimitsConfig holds global speed limits in KB/s; 0 means unlimited.
+type SpeedLimitsConfig struct {
+	Down int64 `mapstructure:"down"`
+	Up   int64 `mapstructure:"up"`
+}
+
+// BandwidthWindow is a daily time window with its own speed limits.
+type BandwidthWindow struct {
+	Name string `mapstructure:"name"`
+	// Days are the days the window starts on, as in mon or sat; empty means every day.
+	Days []string `mapstructure:"days"`
+	// Start and End are times of day as HH:MM; a window may span midnight.
+	Start  string            `mapstructure:"start"`
+	End    string            `mapstructure:"end"`
+	Limits SpeedLimitsConfig `mapstructure:"limits"`
+}
+
+// SettingsConfig holds what /settings may change.
+type SettingsConfig struct {
+	// Editable lists the Transmission session settings admins may change,
+	// by their RPC names such as dht-enabled; the others are only shown.
+	Editable []string `mapstructure:"editable"`
+}
+
+// weekdays maps day abbreviations to days of the week.
+//
+//nolint:gochecknoglobals // static lookup table
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseWeekday reads a day of the week from its first three letters, as in
+// mon or Monday.
+func ParseWeekday(name string) (time.Weekday, bool) {
+	lower := strings.ToLower(name)
+	if len(lower) < len("mon") {
+		return 0, false
+	}
+
+	weekday, ok := weekdays[lower[:len("mon")]]
+
+	return weekday, ok
+}
+
+// ParseClock reads a time of day as HH:MM and returns it in minutes after midnight.
+func ParseClock(text string) (int, error) {
+	clock, err := time.Parse("15:04", text)
+	if err != nil {
+		return 0, fmt.Errorf("parsing time of day: %w", err)
+	}
+
+	return clock.Hour()*MinutesPerHour + clock.Minute(), nil
+}
+
+// MinutesPerHour converts between the hours and minutes of a time of day as
+// returned by ParseClock.
+const MinutesPerHour = 60
+
 // LogConfig holds logging configuration.
 type LogConfig struct {
 	Level string `mapstructure:"level"`
@@ -45,20 +390,13 @@ type LogConfig struct {
 func Load(configPath string) (*Config, error) {
 	viperInstance := viper.New()
 
-	viperInstance.SetDefault("transmission.url", "http://localhost:9091/transmission/rpc")
-	viperInstance.SetDefault("log.level", "info")
+	setDefaults(viperInstance)
 
 	viperInstance.SetEnvPrefix("TB")
 	viperInstance.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viperInstance.AutomaticEnv()
 
-	// Explicitly bind nested env variables
-	_ = viperInstance.BindEnv("telegram.token", "TB_TELEGRAM_TOKEN")
-	_ = viperInstance.BindEnv("telegram.allowed_users", "TB_TELEGRAM_ALLOWED_USERS")
-	_ = viperInstance.BindEnv("transmission.url", "TB_TRANSMISSION_URL")
-	_ = viperInstance.BindEnv("transmission.username", "TB_TRANSMISSION_USERNAME")
-	_ = viperInstance.BindEnv("transmission.password", "TB_TRANSMISSION_PASSWORD")
-	_ = viperInstance.BindEnv("log.level", "TB_LOG_LEVEL")
+	bindEnv(viperInstance)
 
 	if configPath != "" {
 		viperInstance.SetConfigFile(configPath)
@@ -93,18 +431,347 @@ func Load(configPath string) (*Config, error) {
 	return &cfg, nil
 }
 
+// setDefaults sets the value of every key that has a default.
+func setDefaults(viperInstance *viper.Viper) {
+	viperInstance.SetDefault("telegram.invite_ttl", "24h")
+	viperInstance.SetDefault("telegram.webhook.listen", ":8443")
+	viperInstance.SetDefault("transmission.url", "http://localhost:9091/transmission/rpc")
+	viperInstance.SetDefault("transmission.timeouts.read", "10s")
+	viperInstance.SetDefault("transmission.timeouts.write", "30s")
+	viperInstance.SetDefault("transmission.timeouts.add", "60s")
+	viperInstance.SetDefault("transmission.retry.attempts", 3)
+	viperInstance.SetDefault("transmission.retry.backoff", "500ms")
+	viperInstance.SetDefault("transmission.health_interval", "30s")
+	viperInstance.SetDefault("storage.path", "state.json")
+	viperInstance.SetDefault("shutdown.grace_period", "20s")
+	viperInstance.SetDefault("limits.workers", 4)
+	viperInstance.SetDefault("limits.user_rate_per_minute", 30)
+	viperInstance.SetDefault("limits.user_burst", 10)
+	viperInstance.SetDefault("limits.send_rate_per_second", 25)
+	viperInstance.SetDefault("limits.chat_sends_per_minute", 20)
+	viperInstance.SetDefault("upload.max_size", defaultUploadMaxSize)
+	viperInstance.SetDefault("upload.timeout", "30s")
+	viperInstance.SetDefault("fetch.max_size", defaultFetchMaxSize)
+	viperInstance.SetDefault("fetch.timeout", "30s")
+	viperInstance.SetDefault("import.max_entries", 100)
+	viperInstance.SetDefault("import.max_size", defaultImportMaxSize)
+	viperInstance.SetDefault("import.timeout", "10m")
+	viperInstance.SetDefault("progress.interval", "10s")
+	viperInstance.SetDefault("progress.max_watch", "2h")
+	viperInstance.SetDefault("progress.max_cards", 10)
+	viperInstance.SetDefault("dashboard.interval", "1m")
+	viperInstance.SetDefault("notify.interval", "1m")
+	viperInstance.SetDefault("notify.stall_after", "30m")
+	viperInstance.SetDefault("notify.min_free_space", defaultNotifyMinFreeSpace)
+	viperInstance.SetDefault("bandwidth.timezone", "UTC")
+	viperInstance.SetDefault("settings.editable", []string{
+		"peer-limit-global", "peer-limit-per-torrent", "dht-enabled", "pex-enabled", "lpd-enabled",
+		"encryption", "seedRatioLimited", "seedRatioLimit", "download-queue-enabled", "download-queue-size",
+		"incomplete-dir-enabled", "incomplete-dir",
+	})
+	viperInstance.SetDefault("log.level", "info")
+}
+
+// bindEnv explicitly binds nested keys to their environment variables.
+func bindEnv(viperInstance *viper.Viper) {
+	_ = viperInstance.BindEnv("telegram.token", "TB_TELEGRAM_TOKEN")
+	_ = viperInstance.BindEnv("telegram.allowed_users", "TB_TELEGRAM_ALLOWED_USERS")
+	_ = viperInstance.BindEnv("telegram.allowed_chats", "TB_TELEGRAM_ALLOWED_CHATS")
+	_ = viperInstance.BindEnv("telegram.invite_ttl", "TB_TELEGRAM_INVITE_TTL")
+	_ = viperInstance.BindEnv("telegram.webhook.url", "TB_TELEGRAM_WEBHOOK_URL")
+	_ = viperInstance.BindEnv("telegram.webhook.listen", "TB_TELEGRAM_WEBHOOK_LISTEN")
+	_ = viperInstance.BindEnv("telegram.webhook.secret_token", "TB_TELEGRAM_WEBHOOK_SECRET_TOKEN")
+	_ = viperInstance.BindEnv("telegram.webhook.cert_file", "TB_TELEGRAM_WEBHOOK_CERT_FILE")
+	_ = viperInstance.BindEnv("telegram.webhook.key_file", "TB_TELEGRAM_WEBHOOK_KEY_FILE")
+	_ = viperInstance.BindEnv("transmission.url", "TB_TRANSMISSION_URL")
+	_ = viperInstance.BindEnv("transmission.username", "TB_TRANSMISSION_USERNAME")
+	_ = viperInstance.BindEnv("transmission.password", "TB_TRANSMISSION_PASSWORD")
+	_ = viperInstance.BindEnv("transmission.timeouts.read", "TB_TRANSMISSION_TIMEOUTS_READ")
+	_ = viperInstance.BindEnv("transmission.timeouts.write", "TB_TRANSMISSION_TIMEOUTS_WRITE")
+	_ = viperInstance.BindEnv("transmission.timeouts.add", "TB_TRANSMISSION_TIMEOUTS_ADD")
+	_ = viperInstance.BindEnv("transmission.retry.attempts", "TB_TRANSMISSION_RETRY_ATTEMPTS")
+	_ = viperInstance.BindEnv("transmission.retry.backoff", "TB_TRANSMISSION_RETRY_BACKOFF")
+	_ = viperInstance.BindEnv("transmission.health_interval", "TB_TRANSMISSION_HEALTH_INTERVAL")
+	_ = viperInstance.BindEnv("storage.path", "TB_STORAGE_PATH")
+	_ = viperInstance.BindEnv("health.listen", "TB_HEALTH_LISTEN")
+	_ = viperInstance.BindEnv("shutdown.grace_period", "TB_SHUTDOWN_GRACE_PERIOD")
+	_ = viperInstance.BindEnv("limits.workers", "TB_LIMITS_WORKERS")
+	_ = viperInstance.BindEnv("limits.user_rate_per_minute", "TB_LIMITS_USER_RATE_PER_MINUTE")
+	_ = viperInstance.BindEnv("limits.user_burst", "TB_LIMITS_USER_BURST")
+	_ = viperInstance.BindEnv("limits.send_rate_per_second", "TB_LIMITS_SEND_RATE_PER_SECOND")
+	_ = viperInstance.BindEnv("limits.chat_sends_per_minute", "TB_LIMITS_CHAT_SENDS_PER_MINUTE")
+	_ = viperInstance.BindEnv("upload.max_size", "TB_UPLOAD_MAX_SIZE")
+	_ = viperInstance.BindEnv("upload.timeout", "TB_UPLOAD_TIMEOUT")
+	_ = viperInstance.BindEnv("upload.download_dirs", "TB_UPLOAD_DOWNLOAD_DIRS")
+	_ = viperInstance.BindEnv("fetch.max_size", "TB_FETCH_MAX_SIZE")
+	_ = viperInstance.BindEnv("fetch.timeout", "TB_FETCH_TIMEOUT")
+	_ = viperInstance.BindEnv("magnet.trackers", "TB_MAGNET_TRACKERS")
+	_ = viperInstance.BindEnv("import.max_entries", "TB_IMPORT_MAX_ENTRIES")
+	_ = viperInstance.BindEnv("import.max_size", "TB_IMPORT_MAX_SIZE")
+	_ = viperInstance.BindEnv("import.timeout", "TB_IMPORT_TIMEOUT")
+	_ = viperInstance.BindEnv("progress.interval", "TB_PROGRESS_INTERVAL")
+	_ = viperInstance.BindEnv("progress.max_watch", "TB_PROGRESS_MAX_WATCH")
+	_ = viperInstance.BindEnv("progress.max_cards", "TB_PROGRESS_MAX_CARDS")
+	_ = viperInstance.BindEnv("dashboard.interval", "TB_DASHBOARD_INTERVAL")
+	_ = viperInstance.BindEnv("notify.interval", "TB_NOTIFY_INTERVAL")
+	_ = viperInstance.BindEnv("notify.stall_after", "TB_NOTIFY_STALL_AFTER")
+	_ = viperInstance.BindEnv("notify.min_free_space", "TB_NOTIFY_MIN_FREE_SPACE")
+	_ = viperInstance.BindEnv("bandwidth.timezone", "TB_BANDWIDTH_TIMEZONE")
+	_ = viperInstance.BindEnv("settings.editable", "TB_SETTINGS_EDITABLE")
+	_ = viperInstance.BindEnv("log.level", "TB_LOG_LEVEL")
+}
+
 // Validate checks that all required configuration fields are set.
 func (c *Config) Validate() error {
-	if c.Telegram.Token == "" {
+	telegramErr := c.Telegram.validate()
+	if telegramErr != nil {
+		return telegramErr
+	}
+
+	transmissionErr := c.Transmission.validate()
+	if transmissionErr != nil {
+		return transmissionErr
+	}
+
+	if c.Storage.Path == "" {
+		return ErrMissingStoragePath
+	}
+
+	if c.Shutdown.GracePeriod < 0 {
+		return ErrInvalidGracePeriod
+	}
+
+	limitsErr := c.Limits.validate()
+	if limitsErr != nil {
+		return limitsErr
+	}
+
+	uploadErr := c.Upload.validate()
+	if uploadErr != nil {
+		return uploadErr
+	}
+
+	fetchErr := c.Fetch.validate()
+	if fetchErr != nil {
+		return fetchErr
+	}
+
+	importErr := c.Import.validate()
+	if importErr != nil {
+		return importErr
+	}
+
+	updatesErr := c.validateUpdates()
+	if updatesErr != nil {
+		return updatesErr
+	}
+
+	bandwidthErr := c.Bandwidth.validate()
+	if bandwidthErr != nil {
+		return bandwidthErr
+	}
+
+	return c.Magnet.validate()
+}
+
+// validateUpdates checks the settings of what the bot sends on its own:
+// progress cards, dashboards and notifications.
+func (c *Config) validateUpdates() error {
+	if c.Progress.Interval <= 0 || c.Progress.MaxWatch <= 0 || c.Progress.MaxCards < 0 {
+		return ErrInvalidProgress
+	}
+
+	if c.Dashboard.Interval <= 0 {
+		return ErrInvalidDashboard
+	}
+
+	if c.Notify.Interval <= 0 || c.Notify.StallAfter <= 0 || c.Notify.MinFreeSpace < 0 {
+		return ErrInvalidNotify
+	}
+
+	return nil
+}
+
+func (t *TelegramConfig) validate() error {
+	if t.Token == "" {
 		return ErrMissingToken
 	}
 
-	if len(c.Telegram.AllowedUsers) == 0 {
+	if len(t.AllowedUsers) == 0 && len(t.Users) == 0 {
 		return ErrMissingAllowedUsers
 	}
 
-	if c.Transmission.URL == "" {
-		return ErrMissingURL
+	for _, user := range t.Users {
+		if user.ID == 0 {
+			return ErrInvalidUserID
+		}
+
+		if !user.Role.Valid() {
+			return fmt.Errorf("%w: %q for user %d", ErrInvalidRole, user.Role, user.ID)
+		}
+	}
+
+	if t.InviteTTL <= 0 {
+		return ErrInvalidInviteTTL
+	}
+
+	return t.Webhook.validate()
+}
+
+func (t *TransmissionConfig) validate() error {
+	names := make(map[string]struct{}, len(t.Instances))
+
+	for _, instance := range t.InstanceList() {
+		if instance.URL == "" {
+			return fmt.Errorf("%w: instance %q", ErrMissingURL, instance.Name)
+		}
+
+		if _, duplicate := names[instance.Name]; duplicate || !instanceNameRegex.MatchString(instance.Name) {
+			return fmt.Errorf("%w: %q", ErrInvalidInstance, instance.Name)
+		}
+
+		names[instance.Name] = struct{}{}
+	}
+
+	if t.Timeouts.Read <= 0 || t.Timeouts.Write <= 0 || t.Timeouts.Add <= 0 {
+		return ErrInvalidTimeout
+	}
+
+	if t.Retry.Attempts < 1 || t.Retry.Backoff < 0 {
+		return ErrInvalidRetry
+	}
+
+	if t.HealthInterval < 0 {
+		return ErrInvalidHealthCheck
+	}
+
+	return nil
+}
+
+func (l *LimitsConfig) validate() error {
+	if l.Workers < 1 {
+		return ErrInvalidWorkers
+	}
+
+	if l.UserRatePerMinute < 0 {
+		return ErrInvalidUserRate
+	}
+
+	if l.UserRatePerMinute > 0 && l.UserBurst < 1 {
+		return ErrInvalidUserBurst
+	}
+
+	if l.SendRatePerSecond <= 0 || l.ChatSendsPerMinute <= 0 {
+		return ErrInvalidSendRate
+	}
+
+	return nil
+}
+
+func (f *FetchConfig) validate() error {
+	if f.MaxSize <= 0 || f.Timeout <= 0 {
+		return ErrInvalidFetch
+	}
+
+	return nil
+}
+
+func (i *ImportConfig) validate() error {
+	if i.MaxEntries < 1 || i.MaxSize <= 0 || i.Timeout <= 0 {
+		return ErrInvalidImport
+	}
+
+	return nil
+}
+
+func (m *MagnetConfig) validate() error {
+	for _, tracker := range m.Trackers {
+		parsed, err := url.Parse(tracker)
+		if err != nil || parsed.Host == "" {
+			return fmt.Errorf("%w: %q", ErrInvalidTracker, tracker)
+		}
+
+		switch parsed.Scheme {
+		case "http", "https", "udp", "wss":
+		default:
+			return fmt.Errorf("%w: %q", ErrInvalidTracker, tracker)
+		}
+	}
+
+	return nil
+}
+
+func (b *BandwidthConfig) validate() error {
+	_, err := time.LoadLocation(b.Timezone)
+	if err != nil {
+		return fmt.Errorf("%w: bandwidth.timezone %q", ErrInvalidTimezone, b.Timezone)
+	}
+
+	if b.Default.Down < 0 || b.Default.Up < 0 {
+		return ErrInvalidBandwidth
+	}
+
+	for _, window := range b.Windows {
+		windowErr := window.validate()
+		if windowErr != nil {
+			return windowErr
+		}
+	}
+
+	return nil
+}
+
+func (w *BandwidthWindow) validate() error {
+	if w.Name == "" || w.Limits.Down < 0 || w.Limits.Up < 0 {
+		return fmt.Errorf("%w: window %q", ErrInvalidBandwidth, w.Name)
+	}
+
+	for _, day := range w.Days {
+		if _, ok := ParseWeekday(day); !ok {
+			return fmt.Errorf("%w: window %q has day %q", ErrInvalidBandwidth, w.Name, day)
+		}
+	}
+
+	start, startErr := ParseClock(w.Start)
+	end, endErr := ParseClock(w.End)
+
+	if startErr != nil || endErr != nil || start == end {
+		return fmt.Errorf("%w: window %q runs from %q to %q", ErrInvalidBandwidth, w.Name, w.Start, w.End)
+	}
+
+	return nil
+}
+
+func (u *UploadConfig) validate() error {
+	if u.MaxSize <= 0 || u.Timeout <= 0 {
+		return ErrInvalidUpload
+	}
+
+	for _, dir := range u.DownloadDirs {
+		if !path.IsAbs(dir) {
+			return fmt.Errorf("%w: %q", ErrInvalidUpload, dir)
+		}
+	}
+
+	return nil
+}
+
+func (w *WebhookConfig) validate() error {
+	if w.URL == "" {
+		return nil
+	}
+
+	parsed, err := url.Parse(w.URL)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+
+	if w.Listen == "" {
+		return ErrMissingListenAddr
+	}
+
+	if (w.CertFile == "") != (w.KeyFile == "") {
+		return ErrMissingWebhookPair
 	}
 
 	return nil
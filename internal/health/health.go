@@ -0,0 +1,69 @@
+// Package health provides the HTTP endpoints used for liveness, readiness and metrics scraping.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// checkTimeout bounds every readiness check.
+const checkTimeout = 5 * time.Second
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+// NewHandler returns a mux serving /healthz, /readyz and /metrics.
+// /readyz succeeds only when every named check passes.
+func NewHandler(checks map[string]Check, gatherer prometheus.Gatherer) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeText(w, http.StatusOK, "ok")
+	})
+
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		failures := runChecks(r.Context(), checks)
+		if len(failures) > 0 {
+			writeText(w, http.StatusServiceUnavailable, strings.Join(failures, "\n"))
+
+			return
+		}
+
+		writeText(w, http.StatusOK, "ok")
+	})
+
+	mux.Handle("GET /metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+
+	return mux
+}
+
+func runChecks(ctx context.Context, checks map[string]Check) []string {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var failures []string
+
+	for name, check := range checks {
+		err := check(ctx)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	sort.Strings(failures)
+
+	return failures
+}
+
+func writeText(w http.ResponseWriter, status int, text string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(text + "\n"))
+}
@@ -0,0 +1,140 @@
+// Package metrics defines the Prometheus metrics exported by the transmission-bot.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const (
+	namespace = "transmission_bot"
+
+	// torrentCountTimeout bounds the Transmission call made on every scrape.
+	torrentCountTimeout = 5 * time.Second
+)
+
+// TorrentCounter returns the number of torrents per status.
+type TorrentCounter func(ctx context.Context) (map[string]int, error)
+
+// Metrics holds all collectors updated by the bot.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	updates         *prometheus.CounterVec
+	commandDuration *prometheus.HistogramVec
+	rpcErrors       *prometheus.CounterVec
+	torrentsAdded   prometheus.Counter
+	torrentsRemoved prometheus.Counter
+}
+
+// New creates the metrics and registers them, along with Go runtime and
+// process collectors, in a dedicated registry.
+func New() *Metrics {
+	metrics := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		updates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "updates_total",
+			Help:      "Telegram updates processed, by update type.",
+		}, []string{"type"}),
+		commandDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "command_duration_seconds",
+			Help:      "Time spent handling bot commands.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"command"}),
+		rpcErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transmission_rpc_errors_total",
+			Help:      "Failed Transmission RPC calls, by method.",
+		}, []string{"method"}),
+		torrentsAdded: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "torrents_added_total",
+			Help:      "Torrents added through the bot.",
+		}),
+		torrentsRemoved: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "torrents_removed_total",
+			Help:      "Torrents removed through the bot.",
+		}),
+	}
+
+	metrics.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metrics.updates,
+		metrics.commandDuration,
+		metrics.rpcErrors,
+		metrics.torrentsAdded,
+		metrics.torrentsRemoved,
+	)
+
+	return metrics
+}
+
+// RegisterTorrentCounter exports a gauge of torrents per status, computed on every scrape.
+func (m *Metrics) RegisterTorrentCounter(counter TorrentCounter) {
+	m.Registry.MustRegister(&torrentCollector{
+		counter: counter,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "torrents"),
+			"Torrents in Transmission, by status.",
+			[]string{"status"}, nil,
+		),
+	})
+}
+
+// ObserveUpdate counts a processed update of the given type.
+func (m *Metrics) ObserveUpdate(updateType string) {
+	m.updates.WithLabelValues(updateType).Inc()
+}
+
+// ObserveCommand records how long a command took.
+func (m *Metrics) ObserveCommand(command string, duration time.Duration) {
+	m.commandDuration.WithLabelValues(command).Observe(duration.Seconds())
+}
+
+// ObserveRPCError counts a failed Transmission RPC call.
+func (m *Metrics) ObserveRPCError(method string) {
+	m.rpcErrors.WithLabelValues(method).Inc()
+}
+
+// TorrentAdded counts a torrent added through the bot.
+func (m *Metrics) TorrentAdded() {
+	m.torrentsAdded.Inc()
+}
+
+// TorrentRemoved counts a torrent removed through the bot.
+func (m *Metrics) TorrentRemoved() {
+	m.torrentsRemoved.Inc()
+}
+
+// torrentCollector queries Transmission at scrape time so the gauge is never stale.
+type torrentCollector struct {
+	counter TorrentCounter
+	desc    *prometheus.Desc
+}
+
+func (c *torrentCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *torrentCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), torrentCountTimeout)
+	defer cancel()
+
+	counts, err := c.counter(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+
+		return
+	}
+
+	for status, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), status)
+	}
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"errors"
+	"maps"
+	"time"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+)
+
+// Invite errors.
+var (
+	ErrInviteNotFound = errors.New("invite not found or already used")
+	ErrInviteExpired  = errors.New("invite expired")
+)
+
+// Grant is a role given to a user at runtime.
+type Grant struct {
+	UserID    int64       `json:"user_id"`
+	Role      config.Role `json:"role"`
+	GrantedBy int64       `json:"granted_by"`
+	GrantedAt time.Time   `json:"granted_at"`
+}
+
+// Invite is a single-use code that grants a role to whoever redeems it first.
+type Invite struct {
+	Code      string      `json:"code"`
+	Role      config.Role `json:"role"`
+	CreatedBy int64       `json:"created_by"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// SaveGrant stores or replaces the runtime role of a user.
+func (s *Store) SaveGrant(grant Grant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	undo := undoEntry(s.state.Grants, grant.UserID)
+	s.state.Grants[grant.UserID] = grant
+
+	return s.commit(undo)
+}
+
+// RevokeGrant removes the runtime role of a user and reports whether one existed.
+func (s *Store) RevokeGrant(userID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.state.Grants[userID]; !ok {
+		return false, nil
+	}
+
+	undo := undoEntry(s.state.Grants, userID)
+	delete(s.state.Grants, userID)
+
+	return true, s.commit(undo)
+}
+
+// Grant returns the runtime role of a user.
+func (s *Store) Grant(userID int64) (Grant, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grant, ok := s.state.Grants[userID]
+
+	return grant, ok
+}
+
+// Grants returns all runtime grants.
+func (s *Store) Grants() []Grant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grants := make([]Grant, 0, len(s.state.Grants))
+	for _, grant := range s.state.Grants {
+		grants = append(grants, grant)
+	}
+
+	return grants
+}
+
+// CreateInvite stores a new invite, dropping invites that have already expired.
+func (s *Store) CreateInvite(invite Invite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := maps.Clone(s.state.Invites)
+	now := time.Now()
+
+	for code, existing := range s.state.Invites {
+		if now.After(existing.ExpiresAt) {
+			delete(s.state.Invites, code)
+		}
+	}
+
+	s.state.Invites[invite.Code] = invite
+
+	return s.commit(func() { s.state.Invites = previous })
+}
+
+// RedeemInvite consumes an invite and grants its role to the user in a single write.
+func (s *Store) RedeemInvite(code string, userID int64, now time.Time) (Grant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invite, ok := s.state.Invites[code]
+	if !ok {
+		return Grant{}, ErrInviteNotFound
+	}
+
+	undoInvite := undoEntry(s.state.Invites, code)
+	delete(s.state.Invites, code)
+
+	if now.After(invite.ExpiresAt) {
+		return Grant{}, errors.Join(ErrInviteExpired, s.commit(undoInvite))
+	}
+
+	grant := Grant{
+		UserID:    userID,
+		Role:      invite.Role,
+		GrantedBy: invite.CreatedBy,
+		GrantedAt: now,
+	}
+
+	undoGrant := undoEntry(s.state.Grants, userID)
+	s.state.Grants[userID] = grant
+
+	saveErr := s.commit(func() {
+		undoInvite()
+		undoGrant()
+	})
+	if saveErr != nil {
+		return Grant{}, saveErr
+	}
+
+	return grant, nil
+}
@@ -0,0 +1,44 @@
+package store
+
+import "time"
+
+// maxAuditEntries is how many changes the audit log keeps; older ones are dropped.
+const maxAuditEntries = 500
+
+// AuditEntry records a change an admin made to a Transmission setting.
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	UserID   int64     `json:"user_id"`
+	Instance string    `json:"instance"`
+	Setting  string    `json:"setting"`
+	Old      string    `json:"old"`
+	New      string    `json:"new"`
+}
+
+// RecordAudit appends an entry to the audit log.
+func (s *Store) RecordAudit(entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.state.Audit
+
+	s.state.Audit = append(s.state.Audit, entry)
+	if len(s.state.Audit) > maxAuditEntries {
+		s.state.Audit = s.state.Audit[len(s.state.Audit)-maxAuditEntries:]
+	}
+
+	return s.commit(func() { s.state.Audit = previous })
+}
+
+// AuditLog returns up to limit of the latest audit entries, newest first.
+func (s *Store) AuditLog(limit int) []AuditEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]AuditEntry, 0, min(limit, len(s.state.Audit)))
+	for i := len(s.state.Audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, s.state.Audit[i])
+	}
+
+	return entries
+}
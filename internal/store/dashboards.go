@@ -0,0 +1,56 @@
+package store
+
+// Dashboard is a pinned message the bot keeps up to date in a chat.
+type Dashboard struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int   `json:"message_id"`
+}
+
+// SetDashboard stores the dashboard of a chat, replacing any previous one.
+func (s *Store) SetDashboard(dashboard Dashboard) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	undo := undoEntry(s.state.Dashboards, dashboard.ChatID)
+	s.state.Dashboards[dashboard.ChatID] = dashboard
+
+	return s.commit(undo)
+}
+
+// Dashboard returns the dashboard of a chat.
+func (s *Store) Dashboard(chatID int64) (Dashboard, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dashboard, ok := s.state.Dashboards[chatID]
+
+	return dashboard, ok
+}
+
+// Dashboards returns the dashboards of all chats.
+func (s *Store) Dashboards() []Dashboard {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dashboards := make([]Dashboard, 0, len(s.state.Dashboards))
+	for _, dashboard := range s.state.Dashboards {
+		dashboards = append(dashboards, dashboard)
+	}
+
+	return dashboards
+}
+
+// RemoveDashboard forgets the dashboard of a chat if it is still the given message.
+func (s *Store) RemoveDashboard(chatID int64, messageID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.state.Dashboards[chatID]; !ok || existing.MessageID != messageID {
+		return nil
+	}
+
+	undo := undoEntry(s.state.Dashboards, chatID)
+	delete(s.state.Dashboards, chatID)
+
+	return s.commit(undo)
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+)
+
+// migrations upgrade the state one version at a time: migrations[i] moves a state
+// from version i to version i+1. Append new steps; never edit released ones.
+//
+//nolint:gochecknoglobals // Ordered migration table
+var migrations = []func(*state){
+	// 0 -> 1: initial layout.
+	func(st *state) {
+		if st.Ownership == nil {
+			st.Ownership = make(map[string]Ownership)
+		}
+	},
+	// 1 -> 2: user directory for resolving torrent owners.
+	func(st *state) {
+		if st.Users == nil {
+			st.Users = make(map[int64]User)
+		}
+	},
+	// 2 -> 3: runtime grants and invite codes.
+	func(st *state) {
+		if st.Grants == nil {
+			st.Grants = make(map[int64]Grant)
+		}
+
+		if st.Invites == nil {
+			st.Invites = make(map[string]Invite)
+		}
+	},
+	// 3 -> 4: per-user preferences.
+	func(st *state) {
+		if st.Preferences == nil {
+			st.Preferences = make(map[int64]Preferences)
+		}
+	},
+	// 4 -> 5: pinned dashboards.
+	func(st *state) {
+		if st.Dashboards == nil {
+			st.Dashboards = make(map[int64]Dashboard)
+		}
+	},
+	// 5 -> 6: audit log of changed Transmission settings.
+	func(st *state) {
+		if st.Audit == nil {
+			st.Audit = []AuditEntry{}
+		}
+	},
+	// 6 -> 7: ownership keyed by instance and hash. Records from before
+	// multiple instances belong to the single default instance.
+	func(st *state) {
+		rekeyed := make(map[string]Ownership, len(st.Ownership))
+
+		for _, ownership := range st.Ownership {
+			if ownership.Instance == "" {
+				ownership.Instance = config.DefaultInstanceName
+			}
+
+			rekeyed[ownershipKey(ownership.Instance, ownership.Hash)] = ownership
+		}
+
+		st.Ownership = rekeyed
+	},
+}
+
+// currentVersion is the state version written by this build.
+func currentVersion() int {
+	return len(migrations)
+}
+
+// migrate brings the state up to the current version and reports whether anything changed.
+func migrate(st *state) (bool, error) {
+	if st.Version > currentVersion() {
+		return false, fmt.Errorf("%w: %d > %d", ErrUnsupportedVersion, st.Version, currentVersion())
+	}
+
+	migrated := false
+
+	for st.Version < currentVersion() {
+		migrations[st.Version](st)
+		st.Version++
+		migrated = true
+	}
+
+	return migrated, nil
+}
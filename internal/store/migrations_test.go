@@ -0,0 +1,149 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+)
+
+func TestOpenCreatesCurrentVersion(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+
+	_, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	saved := readState(t, path)
+	if saved.Version != currentVersion() {
+		t.Errorf("version = %d, want %d", saved.Version, currentVersion())
+	}
+
+	if saved.Ownership == nil || saved.Users == nil || saved.Grants == nil || saved.Invites == nil ||
+		saved.Preferences == nil || saved.Dashboards == nil || saved.Audit == nil {
+		t.Errorf("migrated state has nil collections: %+v", saved)
+	}
+}
+
+func TestOpenMigratesVersionZero(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	writeFile(t, path, `{"ownership":{"abc":{"hash":"abc","user_id":42,"name":"Ubuntu"}}}`)
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	ownership, ok := store.Ownership(config.DefaultInstanceName, "abc")
+	if !ok || ownership.UserID != 42 || ownership.Instance != config.DefaultInstanceName {
+		t.Errorf("Ownership(default, abc) = %+v, %v; want user 42 on the default instance", ownership, ok)
+	}
+
+	if saved := readState(t, path); saved.Version != currentVersion() {
+		t.Errorf("saved version = %d, want %d", saved.Version, currentVersion())
+	}
+}
+
+func TestOpenKeysOwnershipByInstance(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	writeFile(t, path, `{"version":6,"ownership":{`+
+		`"abc":{"hash":"abc","instance":"nas","user_id":1},`+
+		`"def":{"hash":"def","user_id":2}}}`)
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	if ownership, ok := store.Ownership("nas", "abc"); !ok || ownership.UserID != 1 {
+		t.Errorf("Ownership(nas, abc) = %+v, %v; want user 1", ownership, ok)
+	}
+
+	if ownership, ok := store.Ownership(config.DefaultInstanceName, "def"); !ok || ownership.UserID != 2 {
+		t.Errorf("Ownership(default, def) = %+v, %v; want user 2", ownership, ok)
+	}
+
+	// The same hash on another instance has its own record.
+	err = store.RecordOwnership(Ownership{Hash: "abc", Instance: "seedbox", UserID: 3})
+	if err != nil {
+		t.Fatalf("RecordOwnership() error = %v", err)
+	}
+
+	err = store.ForgetOwnership("nas", "abc")
+	if err != nil {
+		t.Fatalf("ForgetOwnership() error = %v", err)
+	}
+
+	if _, ok := store.Ownership("nas", "abc"); ok {
+		t.Error("Ownership(nas, abc) still present after ForgetOwnership")
+	}
+
+	if ownership, ok := store.Ownership("seedbox", "abc"); !ok || ownership.UserID != 3 {
+		t.Errorf("Ownership(seedbox, abc) = %+v, %v; want user 3", ownership, ok)
+	}
+}
+
+func TestOpenRejectsNewerVersion(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	writeFile(t, path, `{"version":999}`)
+
+	_, err := Open(path)
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Open() error = %v, want %v", err, ErrUnsupportedVersion)
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	t.Parallel()
+
+	var st state
+
+	migrated, err := migrate(&st)
+	if err != nil || !migrated {
+		t.Fatalf("first migrate() = %v, %v; want true, nil", migrated, err)
+	}
+
+	migrated, err = migrate(&st)
+	if err != nil || migrated {
+		t.Errorf("second migrate() = %v, %v; want false, nil", migrated, err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	err := os.WriteFile(path, []byte(content), fileMode)
+	if err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+}
+
+func readState(t *testing.T, path string) state {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+
+	var saved state
+
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", path, err)
+	}
+
+	return saved
+}
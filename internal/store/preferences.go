@@ -0,0 +1,128 @@
+package store
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// Digest frequencies.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Preferences are per-user settings chosen through the bot.
+type Preferences struct {
+	// Instance is the Transmission instance selected with /use.
+	Instance string `json:"instance,omitempty"`
+	// Digest schedules the periodic summary chosen with /digest; nil when off.
+	Digest *Digest `json:"digest,omitempty"`
+	// Notifications are the choices made with /notify.
+	Notifications Notifications `json:"notifications,omitzero"`
+	// Held are the notifications held back during quiet hours, oldest first,
+	// until they are sent when the quiet hours end.
+	Held []string `json:"held,omitempty"`
+}
+
+// Notifications are which notifications a user gets and when.
+type Notifications struct {
+	// Muted lists the events the user turned off.
+	Muted []string `json:"muted,omitempty"`
+	// Enabled lists the events that are off by default which the user turned on.
+	Enabled []string `json:"enabled,omitempty"`
+	// AllTorrents extends torrent events to torrents added by anyone, not
+	// just the user's own.
+	AllTorrents bool `json:"all_torrents,omitempty"`
+	// Quiet holds back or silences notifications at night; nil when off.
+	Quiet *QuietHours `json:"quiet,omitempty"`
+}
+
+// QuietHours is a daily window in which notifications don't disturb.
+type QuietHours struct {
+	// Start and End are minutes after midnight; a window may span midnight.
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// Timezone is the IANA name of the zone Start and End are in.
+	Timezone string `json:"timezone"`
+	// Hold delays notifications until the window ends instead of delivering them silently.
+	Hold bool `json:"hold,omitempty"`
+}
+
+// Digest is when a user receives the periodic summary.
+type Digest struct {
+	// Frequency is DigestDaily or DigestWeekly.
+	Frequency string `json:"frequency"`
+	// Weekday is the day of weekly digests.
+	Weekday time.Weekday `json:"weekday,omitempty"`
+	Hour    int          `json:"hour"`
+	Minute  int          `json:"minute"`
+	// Timezone is the IANA name of the zone Hour and Minute are in, such as Europe/Berlin.
+	Timezone string `json:"timezone"`
+	// Baseline is the cumulative traffic of each instance, by name, when the
+	// last digest was sent; the next digest reports the difference.
+	Baseline map[string]Transfer `json:"baseline,omitempty"`
+}
+
+// Transfer is the cumulative traffic of a Transmission instance, in bytes.
+type Transfer struct {
+	Downloaded int64 `json:"downloaded"`
+	Uploaded   int64 `json:"uploaded"`
+}
+
+// clone returns a deep copy of p, so callers can't change the stored one.
+func (p Preferences) clone() Preferences {
+	if p.Digest != nil {
+		digest := *p.Digest
+		digest.Baseline = maps.Clone(digest.Baseline)
+		p.Digest = &digest
+	}
+
+	p.Notifications.Muted = slices.Clone(p.Notifications.Muted)
+	p.Notifications.Enabled = slices.Clone(p.Notifications.Enabled)
+	p.Held = slices.Clone(p.Held)
+
+	if p.Notifications.Quiet != nil {
+		quiet := *p.Notifications.Quiet
+		p.Notifications.Quiet = &quiet
+	}
+
+	return p
+}
+
+// Preferences returns a copy of the preferences of a user, or zero values if
+// none were saved.
+func (s *Store) Preferences(userID int64) Preferences {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state.Preferences[userID].clone()
+}
+
+// AllPreferences returns a copy of the saved preferences of every user, keyed by user ID.
+func (s *Store) AllPreferences() map[int64]Preferences {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := make(map[int64]Preferences, len(s.state.Preferences))
+	for userID, prefs := range s.state.Preferences {
+		all[userID] = prefs.clone()
+	}
+
+	return all
+}
+
+// UpdatePreferences applies update to a copy of the preferences of a user and
+// saves them.
+func (s *Store) UpdatePreferences(userID int64, update func(*Preferences)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	undo := undoEntry(s.state.Preferences, userID)
+
+	prefs := s.state.Preferences[userID].clone()
+	update(&prefs)
+	s.state.Preferences[userID] = prefs
+
+	return s.commit(undo)
+}
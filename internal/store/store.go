@@ -0,0 +1,273 @@
+// Package store provides file-backed persistent state for the transmission-bot.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	fileMode = 0o600
+	dirMode  = 0o750
+)
+
+// ErrUnsupportedVersion is returned when the state file was written by a newer version of the bot.
+var ErrUnsupportedVersion = errors.New("state file version is newer than supported")
+
+// Store keeps bot metadata in a JSON file and rewrites it atomically on every change.
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	state state
+}
+
+// state is the on-disk representation of the store.
+type state struct {
+	Version     int                   `json:"version"`
+	Ownership   map[string]Ownership  `json:"ownership"`
+	Users       map[int64]User        `json:"users"`
+	Grants      map[int64]Grant       `json:"grants"`
+	Invites     map[string]Invite     `json:"invites"`
+	Preferences map[int64]Preferences `json:"preferences"`
+	Dashboards  map[int64]Dashboard   `json:"dashboards"`
+	Audit       []AuditEntry          `json:"audit"`
+}
+
+// User holds the last known Telegram profile of a user who talked to the bot.
+type User struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	// PrivateChat is set once the user has written to the bot in a private
+	// chat; the bot can't start one itself.
+	PrivateChat bool `json:"private_chat,omitempty"`
+}
+
+// Ownership records which Telegram user added a torrent. Records are keyed by
+// instance and hash, since the same torrent may be added to several instances.
+type Ownership struct {
+	Hash      string    `json:"hash"`
+	Instance  string    `json:"instance,omitempty"`
+	TorrentID int64     `json:"torrent_id"`
+	Name      string    `json:"name"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+// Open loads the store from path, creating it if it does not exist, and applies pending migrations.
+func Open(path string) (*Store, error) {
+	store := &Store{path: path}
+
+	data, readErr := os.ReadFile(path)
+
+	switch {
+	case errors.Is(readErr, os.ErrNotExist):
+	case readErr != nil:
+		return nil, fmt.Errorf("reading state file: %w", readErr)
+	default:
+		unmarshalErr := json.Unmarshal(data, &store.state)
+		if unmarshalErr != nil {
+			return nil, fmt.Errorf("parsing state file: %w", unmarshalErr)
+		}
+	}
+
+	migrated, migrateErr := migrate(&store.state)
+	if migrateErr != nil {
+		return nil, migrateErr
+	}
+
+	if migrated {
+		saveErr := store.save()
+		if saveErr != nil {
+			return nil, saveErr
+		}
+	}
+
+	return store, nil
+}
+
+// RecordOwnership stores the owner of a torrent, replacing any previous record
+// for the same hash on the same instance.
+func (s *Store) RecordOwnership(ownership Ownership) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := ownershipKey(ownership.Instance, ownership.Hash)
+	undo := undoEntry(s.state.Ownership, key)
+	s.state.Ownership[key] = ownership
+
+	return s.commit(undo)
+}
+
+// Ownership returns the owner record for a torrent hash on an instance.
+func (s *Store) Ownership(instance, hash string) (Ownership, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ownership, ok := s.state.Ownership[ownershipKey(instance, hash)]
+
+	return ownership, ok
+}
+
+// ForgetOwnership removes the owner record for a torrent hash on an instance.
+func (s *Store) ForgetOwnership(instance, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := ownershipKey(instance, hash)
+	if _, ok := s.state.Ownership[key]; !ok {
+		return nil
+	}
+
+	undo := undoEntry(s.state.Ownership, key)
+	delete(s.state.Ownership, key)
+
+	return s.commit(undo)
+}
+
+// ownershipKey is the key of an ownership record, as in nas:<hash>.
+func ownershipKey(instance, hash string) string {
+	return instance + ":" + hash
+}
+
+// RememberUser updates the stored profile of a user, writing only when it
+// changed. A private chat, once seen, stays remembered.
+func (s *Store) RememberUser(user User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.state.Users[user.ID]
+	user.PrivateChat = user.PrivateChat || existing.PrivateChat
+
+	if ok && existing == user {
+		return nil
+	}
+
+	undo := undoEntry(s.state.Users, user.ID)
+	s.state.Users[user.ID] = user
+
+	return s.commit(undo)
+}
+
+// Users returns the stored profiles of every user who talked to the bot.
+func (s *Store) Users() []User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make([]User, 0, len(s.state.Users))
+	for _, user := range s.state.Users {
+		users = append(users, user)
+	}
+
+	return users
+}
+
+// User returns the stored profile of a user.
+func (s *Store) User(userID int64) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.state.Users[userID]
+
+	return user, ok
+}
+
+// commit saves the state and, if that fails, calls undo to revert the change
+// just made in memory, so the state matches the file again. Callers must hold s.mu.
+func (s *Store) commit(undo func()) error {
+	err := s.save()
+	if err != nil {
+		undo()
+	}
+
+	return err
+}
+
+// undoEntry returns a func that puts the entry of key back as it is now,
+// deleting it if there is none.
+func undoEntry[K comparable, V any](entries map[K]V, key K) func() {
+	previous, existed := entries[key]
+
+	return func() {
+		if existed {
+			entries[key] = previous
+		} else {
+			delete(entries, key)
+		}
+	}
+}
+
+// save writes the state to a temporary file and renames it over the store path.
+// Callers must hold s.mu unless the store is not yet shared.
+func (s *Store) save() error {
+	data, marshalErr := json.MarshalIndent(s.state, "", "  ")
+	if marshalErr != nil {
+		return fmt.Errorf("encoding state: %w", marshalErr)
+	}
+
+	dir := filepath.Dir(s.path)
+
+	mkdirErr := os.MkdirAll(dir, dirMode)
+	if mkdirErr != nil {
+		return fmt.Errorf("creating state directory: %w", mkdirErr)
+	}
+
+	tmp, createErr := os.CreateTemp(dir, "."+filepath.Base(s.path)+".*")
+	if createErr != nil {
+		return fmt.Errorf("creating temporary state file: %w", createErr)
+	}
+
+	tmpPath := tmp.Name()
+
+	writeErr := writeAndSync(tmp, data)
+	if writeErr != nil {
+		_ = os.Remove(tmpPath)
+
+		return writeErr
+	}
+
+	renameErr := os.Rename(tmpPath, s.path)
+	if renameErr != nil {
+		_ = os.Remove(tmpPath)
+
+		return fmt.Errorf("replacing state file: %w", renameErr)
+	}
+
+	return nil
+}
+
+func writeAndSync(file *os.File, data []byte) error {
+	_, writeErr := file.Write(data)
+	if writeErr != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("writing state file: %w", writeErr)
+	}
+
+	syncErr := file.Sync()
+	if syncErr != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("syncing state file: %w", syncErr)
+	}
+
+	chmodErr := file.Chmod(fileMode)
+	if chmodErr != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("setting state file mode: %w", chmodErr)
+	}
+
+	closeErr := file.Close()
+	if closeErr != nil {
+		return fmt.Errorf("closing state file: %w", closeErr)
+	}
+
+	return nil
+}
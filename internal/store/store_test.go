@@ -0,0 +1,187 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/transmission-bot/internal/config"
+)
+
+// TestFailedSaveKeepsMemory checks that a change whose write fails leaves the
+// store as it was, so memory doesn't drift from the file.
+func TestFailedSaveKeepsMemory(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		change func(*Store) error
+	}{
+		{name: "redeem invite", change: func(s *Store) error {
+			_, err := s.RedeemInvite("code", 7, now)
+
+			return err
+		}},
+		{name: "redeem expired invite", change: func(s *Store) error {
+			_, err := s.RedeemInvite("code", 7, now.Add(2*time.Hour))
+
+			return err
+		}},
+		{name: "create invite", change: func(s *Store) error {
+			return s.CreateInvite(Invite{Code: "new", Role: config.RoleUser, ExpiresAt: now.Add(time.Hour)})
+		}},
+		{name: "save grant", change: func(s *Store) error {
+			return s.SaveGrant(Grant{UserID: 8, Role: config.RoleAdmin})
+		}},
+		{name: "revoke grant", change: func(s *Store) error {
+			_, err := s.RevokeGrant(1)
+
+			return err
+		}},
+		{name: "update preferences", change: func(s *Store) error {
+			return s.UpdatePreferences(1, func(prefs *Preferences) { prefs.Instance = "other" })
+		}},
+		{name: "record ownership", change: func(s *Store) error {
+			return s.RecordOwnership(Ownership{Hash: "def", Instance: "nas"})
+		}},
+		{name: "forget ownership", change: func(s *Store) error {
+			return s.ForgetOwnership("nas", "abc")
+		}},
+		{name: "remember user", change: func(s *Store) error {
+			return s.RememberUser(User{ID: 1, Username: "renamed"})
+		}},
+		{name: "set dashboard", change: func(s *Store) error {
+			return s.SetDashboard(Dashboard{ChatID: 1, MessageID: 2})
+		}},
+		{name: "remove dashboard", change: func(s *Store) error {
+			return s.RemoveDashboard(5, 6)
+		}},
+		{name: "record audit", change: func(s *Store) error {
+			return s.RecordAudit(AuditEntry{Setting: "dht-enabled"})
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			store, dir := seededStore(t, now)
+			before := readState(t, store.path)
+
+			// A file where the state directory was makes every save fail.
+			err := os.RemoveAll(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			writeFile(t, dir, "")
+
+			if test.change(store) == nil {
+				t.Fatal("change succeeded although the state can't be saved")
+			}
+
+			store.mu.Lock()
+			defer store.mu.Unlock()
+
+			if !sameState(t, before, store.state) {
+				t.Errorf("state changed after a failed save:\n got %+v\nwant %+v", store.state, before)
+			}
+		})
+	}
+}
+
+// seededStore opens a store holding one of everything and returns it with
+// the directory of its file.
+func seededStore(t *testing.T, now time.Time) (*Store, string) {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "state")
+
+	store, err := Open(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	for _, seed := range []error{
+		store.CreateInvite(Invite{Code: "code", Role: config.RoleUser, ExpiresAt: now.Add(time.Hour)}),
+		store.SaveGrant(Grant{UserID: 1, Role: config.RoleUser}),
+		store.UpdatePreferences(1, func(prefs *Preferences) { prefs.Instance = "nas" }),
+		store.RecordOwnership(Ownership{Hash: "abc", Instance: "nas"}),
+		store.RememberUser(User{ID: 1, Username: "alice"}),
+		store.SetDashboard(Dashboard{ChatID: 5, MessageID: 6}),
+		store.RecordAudit(AuditEntry{Setting: "pex-enabled"}),
+	} {
+		if seed != nil {
+			t.Fatalf("seeding the store: %v", seed)
+		}
+	}
+
+	return store, dir
+}
+
+// sameState compares states as they would be written.
+func sameState(t *testing.T, want, got state) bool {
+	t.Helper()
+
+	wantJSON, wantErr := json.Marshal(want)
+	gotJSON, gotErr := json.Marshal(got)
+
+	if wantErr != nil || gotErr != nil {
+		t.Fatalf("encoding state: %v, %v", wantErr, gotErr)
+	}
+
+	return string(wantJSON) == string(gotJSON)
+}
+
+func TestPreferencesAreCopies(t *testing.T) {
+	t.Parallel()
+
+	store, err := Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	err = store.UpdatePreferences(1, func(prefs *Preferences) {
+		prefs.Digest = &Digest{Frequency: DigestDaily, Baseline: map[string]Transfer{"nas": {Downloaded: 1}}}
+		prefs.Notifications = Notifications{Muted: []string{"errors"}, Quiet: &QuietHours{Start: 60, End: 120}}
+	})
+	if err != nil {
+		t.Fatalf("UpdatePreferences() error = %v", err)
+	}
+
+	want := readState(t, store.path)
+
+	tests := []struct {
+		name   string
+		change func(*Store)
+	}{
+		{name: "preferences", change: func(s *Store) {
+			prefs := s.Preferences(1)
+			prefs.Digest.Baseline["nas"] = Transfer{Downloaded: 2}
+			prefs.Digest.Hour = 5
+			prefs.Notifications.Muted[0] = "disk"
+			prefs.Notifications.Quiet.Hold = true
+		}},
+		{name: "all preferences", change: func(s *Store) {
+			prefs := s.AllPreferences()[1]
+			prefs.Digest.Baseline["other"] = Transfer{Uploaded: 3}
+			prefs.Notifications.Quiet.Start = 0
+		}},
+	}
+
+	for _, test := range tests {
+		test.change(store)
+
+		store.mu.Lock()
+		same := sameState(t, want, store.state)
+		store.mu.Unlock()
+
+		if !same {
+			t.Errorf("changing the result of %s changed the store", test.name)
+		}
+	}
+}
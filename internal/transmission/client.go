@@ -2,7 +2,9 @@
 package transmission
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -12,42 +14,245 @@ import (
 	"github.com/lexfrei/transmission-bot/internal/config"
 )
 
-// DefaultTimeout is the default timeout for Transmission RPC requests.
-const DefaultTimeout = 30 * time.Second
-
 // ErrUnexpectedResponse is returned when the Transmission API returns an unexpected response.
 var ErrUnexpectedResponse = errors.New("unexpected response: no torrent added or duplicate")
 
 // Client wraps the Transmission RPC client.
 type Client struct {
+	name         string
 	transmission gotransmission.Client
+	timeouts     config.TimeoutsConfig
+	retry        config.RetryConfig
+	state        stateTracker
+	onError      func(method string)
+}
+
+// Option configures optional Client behavior.
+type Option func(*Client)
+
+// WithErrorHook registers a function called with the RPC method name whenever a call fails.
+func WithErrorHook(hook func(method string)) Option {
+	return func(c *Client) {
+		c.onError = hook
+	}
+}
+
+// WithStateHook registers a function called whenever the State changes.
+func WithStateHook(hook func(from, to State)) Option {
+	return func(c *Client) {
+		c.state.onChange = hook
+	}
 }
 
 // Torrent represents a torrent in Transmission.
 type Torrent struct {
+	// Instance is the name of the Transmission instance holding the torrent.
+	Instance    string
 	ID          int64
+	Hash        string
 	Name        string
 	Status      string
 	PercentDone float64
 	TotalSize   int64
+	Labels      []string
+	// DownloadRate and UploadRate are in bytes per second.
+	DownloadRate   int64
+	UploadRate     int64
+	PeersConnected int
+	// ETA is the estimated time left; negative when Transmission can't tell.
+	ETA time.Duration
+	// DoneAt is when the torrent finished downloading; zero while it hasn't.
+	DoneAt time.Time
+	// Error describes a local error, such as a full disk, that stopped the
+	// torrent; tracker warnings are left out. Empty when there is none.
+	Error string
+}
+
+// Paused reports whether the torrent is stopped.
+func (t *Torrent) Paused() bool {
+	return t.Status == gotransmission.TorrentStatusStopped.String()
+}
+
+// Downloading reports whether the torrent is transferring data to complete.
+func (t *Torrent) Downloading() bool {
+	return t.Status == gotransmission.TorrentStatusDownload.String()
+}
+
+// Complete reports whether all wanted data has been downloaded.
+func (t *Torrent) Complete() bool {
+	return t.PercentDone >= 1
+}
+
+// localError is the Transmission error code of errors other than tracker ones.
+const localError = 3
+
+// torrentFields are the fields requested for every Torrent.
+//
+//nolint:gochecknoglobals // static field list
+var torrentFields = []string{
+	"id", "hashString", "name", "status", "percentDone", "totalSize", "labels",
+	"rateDownload", "rateUpload", "peersConnected", "eta", "doneDate", "error", "errorString",
+}
+
+// newTorrent converts a torrent-get entry requested with torrentFields.
+func (c *Client) newTorrent(torrent *gotransmission.Torrent) Torrent {
+	converted := Torrent{
+		Instance:       c.name,
+		ID:             *torrent.ID,
+		Hash:           *torrent.HashString,
+		Name:           *torrent.Name,
+		Status:         torrent.Status.String(),
+		PercentDone:    *torrent.PercentDone,
+		TotalSize:      *torrent.TotalSize,
+		Labels:         torrent.Labels,
+		DownloadRate:   *torrent.RateDownload,
+		UploadRate:     *torrent.RateUpload,
+		PeersConnected: *torrent.PeersConnected,
+		ETA:            time.Duration(*torrent.ETA) * time.Second,
+	}
+
+	if *torrent.DoneDate > 0 {
+		converted.DoneAt = time.Unix(*torrent.DoneDate, 0)
+	}
+
+	if *torrent.Error == localError {
+		converted.Error = *torrent.ErrorString
+	}
+
+	return converted
+}
+
+// AddResult is the outcome of adding a torrent.
+type AddResult struct {
+	Torrent Torrent
+	// Duplicate is set when the torrent was already in Transmission; Torrent
+	// then describes the existing one and nothing was changed.
+	Duplicate bool
 }
 
-// NewClient creates a new Transmission client with the given configuration.
-func NewClient(cfg config.TransmissionConfig) (*Client, error) {
-	opts := []gotransmission.Option{
-		gotransmission.WithTimeout(DefaultTimeout),
+// AddOptions holds optional parameters applied to newly added torrents.
+type AddOptions struct {
+	Labels []string
+	// Paused adds the torrent without starting it.
+	Paused bool
+	// DownloadDir overrides Transmission's default download directory when set.
+	DownloadDir string
+}
+
+// args returns torrent-add arguments carrying the options.
+func (o *AddOptions) args() *gotransmission.TorrentAddArgs {
+	args := &gotransmission.TorrentAddArgs{Labels: o.Labels}
+
+	if o.Paused {
+		args.Paused = &o.Paused
 	}
 
-	if cfg.Username != "" && cfg.Password != "" {
-		opts = append(opts, gotransmission.WithAuth(cfg.Username, cfg.Password))
+	if o.DownloadDir != "" {
+		args.DownloadDir = &o.DownloadDir
+	}
+
+	return args
+}
+
+// Stats summarizes the current activity of a Transmission instance.
+type Stats struct {
+	TorrentCount       int
+	ActiveTorrentCount int
+	PausedTorrentCount int
+	DownloadSpeed      int64
+	UploadSpeed        int64
+	DownloadedBytes    int64
+	UploadedBytes      int64
+}
+
+// Session holds the session settings the bot shows.
+type Session struct {
+	// DownloadDir is the default directory new torrents are saved to.
+	DownloadDir string
+	// AltSpeedEnabled reports whether the alternative speed limits are in effect.
+	AltSpeedEnabled bool
+}
+
+// AltSpeedSchedule is Transmission's own schedule for its alternative speed limits.
+type AltSpeedSchedule struct {
+	Enabled bool
+	// Begin and End are minutes after midnight, in the Transmission host's time.
+	Begin int
+	End   int
+	// Days is a bitmask of the days the schedule applies: 1 is Sunday, 2
+	// Monday and so on up to 64 for Saturday.
+	Days int
+	// Down and Up are the alternative limits in KB/s.
+	Down int64
+	Up   int64
+}
+
+// SpeedLimits are the global speed limits in KB/s; 0 removes a limit.
+type SpeedLimits struct {
+	Down int64
+	Up   int64
+}
+
+// NewClient creates a client for one Transmission instance. Timeouts and
+// retries come from the shared transmission configuration.
+func NewClient(cfg config.TransmissionConfig, instance config.InstanceConfig, options ...Option) (*Client, error) {
+	// Calls are bounded per operation class through their context instead of a client-wide timeout.
+	var opts []gotransmission.Option
+
+	if instance.Username != "" && instance.Password != "" {
+		opts = append(opts, gotransmission.WithAuth(instance.Username, instance.Password))
 	}
 
-	transmission, err := gotransmission.New(cfg.URL, opts...)
+	transmission, err := gotransmission.New(instance.URL, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("creating transmission client: %w", err)
 	}
 
-	return &Client{transmission: transmission}, nil
+	client := &Client{
+		name:         instance.Name,
+		transmission: transmission,
+		timeouts:     cfg.Timeouts,
+		retry:        cfg.Retry,
+	}
+
+	for _, option := range options {
+		option(client)
+	}
+
+	return client, nil
+}
+
+// Name returns the name of the instance the client talks to.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// State returns how well Transmission has been responding recently.
+func (c *Client) State() State {
+	return c.state.current()
+}
+
+// observe reports a failed call to the error hook and returns err unchanged.
+func (c *Client) observe(method string, err error) error {
+	if err != nil && c.onError != nil {
+		c.onError(method)
+	}
+
+	return err
+}
+
+// Ping performs a cheap session-get to check that Transmission is reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	err := c.call(ctx, opSessionGet, func(ctx context.Context) error {
+		_, err := c.transmission.SessionGet(ctx, []string{"version"})
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("getting session: %w", err)
+	}
+
+	return nil
 }
 
 // Close releases resources associated with the client.
@@ -61,51 +266,53 @@ func (c *Client) Close() error {
 }
 
 // AddTorrentByMagnet adds a torrent using a magnet link.
-func (c *Client) AddTorrentByMagnet(ctx context.Context, magnet string) (*Torrent, error) {
-	result, err := c.transmission.TorrentAdd(ctx, &gotransmission.TorrentAddArgs{
-		Filename: &magnet,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("adding torrent: %w", err)
-	}
+func (c *Client) AddTorrentByMagnet(ctx context.Context, magnet string, opts AddOptions) (*AddResult, error) {
+	args := opts.args()
+	args.Filename = &magnet
 
-	if result.TorrentAdded != nil {
-		return &Torrent{
-			ID:   result.TorrentAdded.ID,
-			Name: result.TorrentAdded.Name,
-		}, nil
-	}
+	return c.addTorrent(ctx, args)
+}
 
-	if result.TorrentDuplicate != nil {
-		return &Torrent{
-			ID:   result.TorrentDuplicate.ID,
-			Name: result.TorrentDuplicate.Name,
-		}, nil
-	}
+// AddTorrentByFile adds a torrent using base64-encoded torrent file data.
+func (c *Client) AddTorrentByFile(ctx context.Context, base64Data string, opts AddOptions) (*AddResult, error) {
+	args := opts.args()
+	args.Metainfo = &base64Data
 
-	return nil, ErrUnexpectedResponse
+	return c.addTorrent(ctx, args)
 }
 
-// AddTorrentByFile adds a torrent using base64-encoded torrent file data.
-func (c *Client) AddTorrentByFile(ctx context.Context, base64Data string) (*Torrent, error) {
-	result, err := c.transmission.TorrentAdd(ctx, &gotransmission.TorrentAddArgs{
-		Metainfo: &base64Data,
+func (c *Client) addTorrent(ctx context.Context, args *gotransmission.TorrentAddArgs) (*AddResult, error) {
+	var result *gotransmission.TorrentAddResult
+
+	err := c.call(ctx, opTorrentAdd, func(ctx context.Context) error {
+		var err error
+
+		result, err = c.transmission.TorrentAdd(ctx, args)
+
+		return err
 	})
 	if err != nil {
 		return nil, fmt.Errorf("adding torrent: %w", err)
 	}
 
 	if result.TorrentAdded != nil {
-		return &Torrent{
-			ID:   result.TorrentAdded.ID,
-			Name: result.TorrentAdded.Name,
-		}, nil
+		return &AddResult{Torrent: Torrent{
+			Instance: c.name,
+			ID:       result.TorrentAdded.ID,
+			Hash:     result.TorrentAdded.HashString,
+			Name:     result.TorrentAdded.Name,
+		}}, nil
 	}
 
 	if result.TorrentDuplicate != nil {
-		return &Torrent{
-			ID:   result.TorrentDuplicate.ID,
-			Name: result.TorrentDuplicate.Name,
+		return &AddResult{
+			Torrent: Torrent{
+				Instance: c.name,
+				ID:       result.TorrentDuplicate.ID,
+				Hash:     result.TorrentDuplicate.HashString,
+				Name:     result.TorrentDuplicate.Name,
+			},
+			Duplicate: true,
 		}, nil
 	}
 
@@ -114,22 +321,14 @@ func (c *Client) AddTorrentByFile(ctx context.Context, base64Data string) (*Torr
 
 // ListTorrents returns a list of all torrents.
 func (c *Client) ListTorrents(ctx context.Context) ([]Torrent, error) {
-	fields := []string{"id", "name", "status", "percentDone", "totalSize"}
-
-	result, err := c.transmission.TorrentGet(ctx, fields, nil)
+	result, err := c.getTorrents(ctx, torrentFields, nil)
 	if err != nil {
 		return nil, fmt.Errorf("getting torrents: %w", err)
 	}
 
 	torrents := make([]Torrent, 0, len(result.Torrents))
-	for _, torrent := range result.Torrents {
-		torrents = append(torrents, Torrent{
-			ID:          *torrent.ID,
-			Name:        *torrent.Name,
-			Status:      torrent.Status.String(),
-			PercentDone: *torrent.PercentDone,
-			TotalSize:   *torrent.TotalSize,
-		})
+	for i := range result.Torrents {
+		torrents = append(torrents, c.newTorrent(&result.Torrents[i]))
 	}
 
 	return torrents, nil
@@ -140,9 +339,7 @@ var ErrTorrentNotFound = errors.New("torrent not found")
 
 // GetTorrent returns a torrent by ID.
 func (c *Client) GetTorrent(ctx context.Context, torrentID int64) (*Torrent, error) {
-	fields := []string{"id", "name", "status", "percentDone", "totalSize"}
-
-	result, err := c.transmission.TorrentGet(ctx, fields, []int64{torrentID})
+	result, err := c.getTorrents(ctx, torrentFields, []int64{torrentID})
 	if err != nil {
 		return nil, fmt.Errorf("getting torrent: %w", err)
 	}
@@ -151,23 +348,248 @@ func (c *Client) GetTorrent(ctx context.Context, torrentID int64) (*Torrent, err
 		return nil, ErrTorrentNotFound
 	}
 
-	torrent := result.Torrents[0]
+	torrent := c.newTorrent(&result.Torrents[0])
 
-	return &Torrent{
-		ID:          *torrent.ID,
-		Name:        *torrent.Name,
-		Status:      torrent.Status.String(),
-		PercentDone: *torrent.PercentDone,
-		TotalSize:   *torrent.TotalSize,
-	}, nil
+	return &torrent, nil
 }
 
 // RemoveTorrent removes a torrent by ID, optionally deleting local data.
 func (c *Client) RemoveTorrent(ctx context.Context, torrentID int64, deleteData bool) error {
-	err := c.transmission.TorrentRemove(ctx, []int64{torrentID}, deleteData)
+	err := c.call(ctx, opTorrentRemove, func(ctx context.Context) error {
+		return c.transmission.TorrentRemove(ctx, []int64{torrentID}, deleteData)
+	})
 	if err != nil {
 		return fmt.Errorf("removing torrent: %w", err)
 	}
 
 	return nil
 }
+
+// StartTorrent resumes a stopped torrent.
+func (c *Client) StartTorrent(ctx context.Context, torrentID int64) error {
+	err := c.call(ctx, opTorrentStart, func(ctx context.Context) error {
+		return c.transmission.TorrentStart(ctx, []int64{torrentID})
+	})
+	if err != nil {
+		return fmt.Errorf("starting torrent: %w", err)
+	}
+
+	return nil
+}
+
+// SessionStats returns the current transfer statistics of the instance.
+func (c *Client) SessionStats(ctx context.Context) (*Stats, error) {
+	var result *gotransmission.SessionStats
+
+	err := c.call(ctx, opSessionStats, func(ctx context.Context) error {
+		var err error
+
+		result, err = c.transmission.SessionStats(ctx)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting session stats: %w", err)
+	}
+
+	return &Stats{
+		TorrentCount:       result.TorrentCount,
+		ActiveTorrentCount: result.ActiveTorrentCount,
+		PausedTorrentCount: result.PausedTorrentCount,
+		DownloadSpeed:      result.DownloadSpeed,
+		UploadSpeed:        result.UploadSpeed,
+		DownloadedBytes:    result.CumulativeStats.DownloadedBytes,
+		UploadedBytes:      result.CumulativeStats.UploadedBytes,
+	}, nil
+}
+
+// Session returns the session settings of the instance.
+func (c *Client) Session(ctx context.Context) (*Session, error) {
+	var result *gotransmission.Session
+
+	err := c.call(ctx, opSessionGet, func(ctx context.Context) error {
+		var err error
+
+		result, err = c.transmission.SessionGet(ctx, []string{"download-dir", "alt-speed-enabled"})
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting session: %w", err)
+	}
+
+	session := &Session{}
+	setIfPresent(&session.DownloadDir, result.DownloadDir)
+	setIfPresent(&session.AltSpeedEnabled, result.AltSpeedEnabled)
+
+	return session, nil
+}
+
+// AltSpeedSchedule returns the alternative speed schedule of the instance.
+func (c *Client) AltSpeedSchedule(ctx context.Context) (*AltSpeedSchedule, error) {
+	var result *gotransmission.Session
+
+	err := c.call(ctx, opSessionGet, func(ctx context.Context) error {
+		var err error
+
+		result, err = c.transmission.SessionGet(ctx, []string{
+			"alt-speed-time-enabled", "alt-speed-time-begin", "alt-speed-time-end",
+			"alt-speed-time-day", "alt-speed-down", "alt-speed-up",
+		})
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting alt-speed schedule: %w", err)
+	}
+
+	schedule := &AltSpeedSchedule{}
+	setIfPresent(&schedule.Enabled, result.AltSpeedTimeEnabled)
+	setIfPresent(&schedule.Begin, result.AltSpeedTimeBegin)
+	setIfPresent(&schedule.End, result.AltSpeedTimeEnd)
+	setIfPresent(&schedule.Days, result.AltSpeedTimeDay)
+	setIfPresent(&schedule.Down, result.AltSpeedDown)
+	setIfPresent(&schedule.Up, result.AltSpeedUp)
+
+	return schedule, nil
+}
+
+// SetAltSpeedSchedule changes when the alternative speed limits apply; the
+// limits themselves are left as they are.
+func (c *Client) SetAltSpeedSchedule(ctx context.Context, schedule AltSpeedSchedule) error {
+	return c.setSession(ctx, &gotransmission.SessionSetArgs{
+		AltSpeedTimeEnabled: &schedule.Enabled,
+		AltSpeedTimeBegin:   &schedule.Begin,
+		AltSpeedTimeEnd:     &schedule.End,
+		AltSpeedTimeDay:     &schedule.Days,
+	})
+}
+
+// SetSpeedLimits changes the global speed limits. It leaves the alternative
+// limits alone, so while they are on they stay in effect.
+func (c *Client) SetSpeedLimits(ctx context.Context, limits SpeedLimits) error {
+	downEnabled, upEnabled := limits.Down > 0, limits.Up > 0
+
+	args := &gotransmission.SessionSetArgs{
+		SpeedLimitDownEnabled: &downEnabled,
+		SpeedLimitUpEnabled:   &upEnabled,
+	}
+
+	if downEnabled {
+		args.SpeedLimitDown = &limits.Down
+	}
+
+	if upEnabled {
+		args.SpeedLimitUp = &limits.Up
+	}
+
+	return c.setSession(ctx, args)
+}
+
+func (c *Client) setSession(ctx context.Context, args *gotransmission.SessionSetArgs) error {
+	err := c.call(ctx, opSessionSet, func(ctx context.Context) error {
+		return c.transmission.SessionSet(ctx, args)
+	})
+	if err != nil {
+		return fmt.Errorf("setting session: %w", err)
+	}
+
+	return nil
+}
+
+// ErrUnknownSetting is returned when a session setting can't be changed or
+// was given a value of the wrong type.
+var ErrUnknownSetting = errors.New("unknown session setting")
+
+// SessionValues returns session settings by their RPC names, such as
+// dht-enabled, decoded from JSON: booleans, numbers as float64 and strings.
+// Settings the instance doesn't report are missing from the result.
+func (c *Client) SessionValues(ctx context.Context, keys []string) (map[string]any, error) {
+	var result *gotransmission.Session
+
+	err := c.call(ctx, opSessionGet, func(ctx context.Context) error {
+		var err error
+
+		result, err = c.transmission.SessionGet(ctx, keys)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting session: %w", err)
+	}
+
+	// The session comes back typed; its JSON form is the RPC one, by name.
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("encoding session: %w", err)
+	}
+
+	values := make(map[string]any, len(keys))
+
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return nil, fmt.Errorf("decoding session: %w", err)
+	}
+
+	return values, nil
+}
+
+// SetSessionValue changes a session setting by its RPC name. The value must
+// have the JSON type of the setting.
+func (c *Client) SetSessionValue(ctx context.Context, key string, value any) error {
+	data, err := json.Marshal(map[string]any{key: value})
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", key, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var args gotransmission.SessionSetArgs
+
+	err = decoder.Decode(&args)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrUnknownSetting, key, err)
+	}
+
+	return c.setSession(ctx, &args)
+}
+
+// setIfPresent copies a session-get value that Transmission returned.
+func setIfPresent[T any](target *T, value *T) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+// FreeSpace returns the bytes available in a directory on the Transmission host.
+func (c *Client) FreeSpace(ctx context.Context, path string) (int64, error) {
+	var result *gotransmission.FreeSpace
+
+	err := c.call(ctx, opFreeSpace, func(ctx context.Context) error {
+		var err error
+
+		result, err = c.transmission.FreeSpace(ctx, path)
+
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("getting free space: %w", err)
+	}
+
+	return result.SizeBytes, nil
+}
+
+func (c *Client) getTorrents(ctx context.Context, fields []string, ids []int64) (*gotransmission.TorrentGetResult, error) {
+	var result *gotransmission.TorrentGetResult
+
+	err := c.call(ctx, opTorrentGet, func(ctx context.Context) error {
+		var err error
+
+		result, err = c.transmission.TorrentGet(ctx, fields, ids)
+
+		return err
+	})
+
+	return result, err
+}
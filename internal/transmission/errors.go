@@ -0,0 +1,84 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	gotransmission "github.com/lexfrei/go-transmission/api/transmission"
+)
+
+// Errors returned by Client methods, to be checked with errors.Is. They wrap
+// the underlying error, so the full details remain available for logging.
+var (
+	// ErrUnauthorized means Transmission rejected the configured credentials (HTTP 401 or 403).
+	ErrUnauthorized = errors.New("transmission rejected the credentials")
+	// ErrSessionConflict means the CSRF session handshake (HTTP 409) could not be completed.
+	ErrSessionConflict = errors.New("transmission session conflict")
+	// ErrTimeout means Transmission did not answer within the configured timeout.
+	ErrTimeout = errors.New("transmission did not respond in time")
+	// ErrConnectionRefused means nothing is listening at the configured URL.
+	ErrConnectionRefused = errors.New("transmission refused the connection")
+	// ErrUnavailable covers other network and server errors.
+	ErrUnavailable = errors.New("transmission is unavailable")
+	// ErrInvalidTorrent means Transmission could not parse the torrent file or magnet link.
+	ErrInvalidTorrent = errors.New("invalid torrent")
+)
+
+// classify wraps an error from go-transmission with the matching domain error.
+// Errors that fit no category are returned unchanged.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var kind error
+
+	var (
+		netErr  net.Error
+		httpErr *gotransmission.HTTPError
+		rpcErr  *gotransmission.RPCError
+	)
+
+	switch {
+	case gotransmission.IsUnauthorized(err) || gotransmission.IsForbidden(err):
+		kind = ErrUnauthorized
+	case errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusConflict,
+		errors.Is(err, gotransmission.ErrCSRFMissing),
+		errors.Is(err, gotransmission.ErrCSRFRetryFailed):
+		kind = ErrSessionConflict
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		kind = ErrTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		kind = ErrConnectionRefused
+	case errors.As(err, &netErr), gotransmission.IsServerError(err), errors.Is(err, gotransmission.ErrClosed):
+		kind = ErrUnavailable
+	case errors.As(err, &rpcErr) && isInvalidTorrentResult(rpcErr.Result):
+		kind = ErrInvalidTorrent
+	default:
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+// isInvalidTorrentResult recognizes the RPC results Transmission uses for
+// unparsable torrents, such as "invalid or corrupt torrent file".
+func isInvalidTorrentResult(result string) bool {
+	result = strings.ToLower(result)
+
+	return strings.Contains(result, "invalid") || strings.Contains(result, "corrupt")
+}
+
+// IsUnavailable reports whether err means Transmission could not be used at all,
+// as opposed to rejecting a particular request.
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrUnauthorized) ||
+		errors.Is(err, ErrTimeout) ||
+		errors.Is(err, ErrConnectionRefused) ||
+		errors.Is(err, ErrUnavailable)
+}
@@ -0,0 +1,111 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	gotransmission "github.com/lexfrei/go-transmission/api/transmission"
+)
+
+// opClass selects which configured timeout applies to a call.
+type opClass int
+
+const (
+	classRead opClass = iota
+	classWrite
+	classAdd
+)
+
+// operation describes an RPC method: its timeout class and whether it is
+// safe to send again when the first attempt may already have been applied.
+type operation struct {
+	method     string
+	class      opClass
+	idempotent bool
+}
+
+//nolint:gochecknoglobals // fixed descriptions of the RPC methods the client uses
+var (
+	opSessionGet    = operation{method: "session-get", class: classRead, idempotent: true}
+	opSessionStats  = operation{method: "session-stats", class: classRead, idempotent: true}
+	opFreeSpace     = operation{method: "free-space", class: classRead, idempotent: true}
+	opSessionSet    = operation{method: "session-set", class: classWrite, idempotent: true}
+	opTorrentGet    = operation{method: "torrent-get", class: classRead, idempotent: true}
+	opTorrentAdd    = operation{method: "torrent-add", class: classAdd, idempotent: false}
+	opTorrentRemove = operation{method: "torrent-remove", class: classWrite, idempotent: false}
+	opTorrentStart  = operation{method: "torrent-start", class: classWrite, idempotent: true}
+)
+
+func (c *Client) timeout(class opClass) time.Duration {
+	switch class {
+	case classWrite:
+		return c.timeouts.Write
+	case classAdd:
+		return c.timeouts.Add
+	default:
+		return c.timeouts.Read
+	}
+}
+
+// call runs fn with the timeout of the operation's class. Idempotent
+// operations are retried with exponential backoff while the error is transient.
+// The final error is classified into the package's domain errors, and every
+// outcome feeds the State.
+func (c *Client) call(ctx context.Context, op operation, fn func(ctx context.Context) error) error {
+	attempts := 1
+	if op.idempotent {
+		attempts = c.retry.Attempts
+	}
+
+	backoff := c.retry.Backoff
+
+	var err error
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, c.timeout(op.class))
+		err = c.observe(op.method, fn(callCtx))
+
+		cancel()
+
+		if err == nil || !isTransient(err) || attempt == attempts {
+			break
+		}
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return c.finish(ctx, err)
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
+
+	return c.finish(ctx, err)
+}
+
+// finish classifies the final error of a call and feeds it to the State.
+// Calls the caller cancelled or ran out of its own deadline say nothing about
+// Transmission, so they are left out of the State.
+func (c *Client) finish(ctx context.Context, err error) error {
+	err = classify(err)
+	if ctx.Err() == nil {
+		c.state.record(err)
+	}
+
+	return err
+}
+
+// isTransient reports whether a failed call may succeed if simply repeated.
+func isTransient(err error) bool {
+	var netErr net.Error
+
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr) ||
+		gotransmission.IsServerError(err)
+}
@@ -0,0 +1,86 @@
+package transmission
+
+import (
+	"sync"
+)
+
+const (
+	// degradedAfter is the number of consecutive failed calls after which
+	// Transmission is considered degraded; a single failure is ignored.
+	degradedAfter = 2
+	// downAfter is the number of consecutive failed calls after which Transmission is considered down.
+	downAfter = 3
+)
+
+// State describes how well Transmission is responding.
+type State int
+
+// Transmission states.
+const (
+	// StateUp means the last call succeeded.
+	StateUp State = iota
+	// StateDegraded means several calls in a row failed, but not enough to give up on it.
+	StateDegraded
+	// StateDown means Transmission has been unreachable for several calls in a row.
+	StateDown
+)
+
+func (s State) String() string {
+	switch s {
+	case StateUp:
+		return "up"
+	case StateDegraded:
+		return "degraded"
+	case StateDown:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+// stateTracker derives the State from the outcome of every call and reports transitions.
+type stateTracker struct {
+	mu       sync.Mutex
+	state    State
+	failures int
+	onChange func(from, to State)
+}
+
+// record updates the state after a call. Errors that show Transmission is
+// reachable, such as a missing torrent, count as success.
+func (t *stateTracker) record(err error) {
+	t.mu.Lock()
+
+	from := t.state
+
+	if IsUnavailable(err) {
+		t.failures++
+	} else {
+		t.failures = 0
+	}
+
+	switch {
+	case t.failures == 0:
+		t.state = StateUp
+	case t.failures >= downAfter:
+		t.state = StateDown
+	case t.failures >= degradedAfter:
+		t.state = max(t.state, StateDegraded)
+	}
+
+	to := t.state
+	onChange := t.onChange
+
+	t.mu.Unlock()
+
+	if from != to && onChange != nil {
+		onChange(from, to)
+	}
+}
+
+func (t *stateTracker) current() State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.state
+}
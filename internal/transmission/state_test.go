@@ -0,0 +1,66 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStateTrackerRecord(t *testing.T) {
+	t.Parallel()
+
+	failure := ErrTimeout
+	rejected := ErrInvalidTorrent
+
+	tests := []struct {
+		name string
+		errs []error
+		want State
+	}{
+		{name: "success", errs: []error{nil}, want: StateUp},
+		{name: "single failure is ignored", errs: []error{failure}, want: StateUp},
+		{name: "two failures degrade", errs: []error{failure, failure}, want: StateDegraded},
+		{name: "three failures are down", errs: []error{failure, failure, failure}, want: StateDown},
+		{name: "failures must be consecutive", errs: []error{failure, nil, failure}, want: StateUp},
+		{name: "rejected request counts as success", errs: []error{failure, failure, rejected}, want: StateUp},
+		{name: "one success recovers", errs: []error{failure, failure, failure, nil}, want: StateUp},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var tracker stateTracker
+			for _, err := range test.errs {
+				tracker.record(err)
+			}
+
+			if got := tracker.current(); got != test.want {
+				t.Errorf("state = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFinishIgnoresCallerCancellation(t *testing.T) {
+	t.Parallel()
+
+	var changes int
+
+	client := &Client{}
+	client.state.onChange = func(_, _ State) { changes++ }
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	for range downAfter {
+		err := client.finish(ctx, context.Canceled)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("finish() error = %v, want %v", err, context.Canceled)
+		}
+	}
+
+	if client.State() != StateUp || changes != 0 {
+		t.Errorf("state = %v after %d changes, want up and no changes", client.State(), changes)
+	}
+}